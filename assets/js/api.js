@@ -113,6 +113,12 @@ class APIRequestAccounts extends APIRequest {
     }
 }
 
+class APIRequestImporters extends APIRequest {
+    constructor() {
+        super('/importers');
+    }
+}
+
 class APIRequestAddAccount extends APIRequest {
     constructor(name, importer) {
         super('/add_account?name=' + encodeURIComponent(name) +
@@ -133,14 +139,19 @@ class APIRequestClearAccount extends APIRequest {
 }
 
 class APIRequestAllTransactions extends APIRequest {
-    constructor() {
-        super('/all_transactions');
+    constructor(start, convert) {
+        super('/all_transactions?convert=' + (convert ? '1' : '0') +
+            (start ? '&start=' + encodeURIComponent(start) : ''));
     }
 }
 
+const TRANSACTIONS_PAGE_SIZE = 100;
+
 class APIRequestTransactions extends APIRequest {
-    constructor(accountID) {
-        super('/transactions?account_id=' + encodeURIComponent(accountID));
+    constructor(accountID, cursor) {
+        super('/transactions?account_id=' + encodeURIComponent(accountID) +
+            '&limit=' + TRANSACTIONS_PAGE_SIZE +
+            (cursor ? '&cursor=' + encodeURIComponent(cursor) : ''));
     }
 }
 
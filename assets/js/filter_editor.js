@@ -107,32 +107,38 @@ class FilterEditorInputField extends FilterEditorField {
 
 class FieldEditorPatternField extends FilterEditorInputField {
     constructor() {
-        super(['Regular expression to exclude']);
+        super(['Regular expression to exclude', 'Field (optional)']);
     }
 
     load(obj) {
         this.inputs[0].value = obj['Pattern'];
+        this.inputs[1].value = obj['Field'] || '';
     }
 
     save() {
-        return { 'Pattern': this.inputs[0].value };
+        return {
+            'Pattern': this.inputs[0].value,
+            'Field': this.inputs[1].value,
+        };
     }
 }
 
 class FieldEditorCategoryField extends FilterEditorInputField {
     constructor() {
-        super(['Regular expression', 'Category']);
+        super(['Regular expression', 'Category', 'Field (optional)']);
     }
 
     load(obj) {
         this.inputs[0].value = obj['Pattern'];
         this.inputs[1].value = obj['Category'];
+        this.inputs[2].value = obj['Field'] || '';
     }
 
     save() {
         return {
             'Pattern': this.inputs[0].value,
             'Category': this.inputs[1].value,
+            'Field': this.inputs[2].value,
         };
     }
 }
@@ -55,11 +55,15 @@ class PageAddAccount extends Page {
 
         this.nameField = document.getElementById('add-account-name');
         this.typeField = document.getElementById('add-account-type');
+        this.typeField.addEventListener('change', () => this.updateJournalField());
+        this.journalField = document.getElementById('add-account-journal');
+        this.journalBreak = document.getElementById('add-account-journal-break');
         this.loader = document.getElementById('add-account-loader');
         this.submitButton = document.getElementById('add-account-submit-button');
         this.submitButton.addEventListener('click', () => this.submit());
         this.errorField = document.getElementById('add-account-error');
         this._request = null;
+        this._importersRequest = null;
     }
 
     name() {
@@ -74,7 +78,10 @@ class PageAddAccount extends Page {
         this.errorField.style.display = 'none';
 
         const name = this.nameField.value;
-        const importer = this.typeField.value;
+        let importer = this.typeField.value;
+        if (this.isJournal()) {
+            importer += this.journalField.value;
+        }
         this._request = new APIRequestAddAccount(name, importer).onData((data) => {
             window.pageManager.replace('account', { 'id': data['ID'] });
         }).onError((err) => {
@@ -93,6 +100,31 @@ class PageAddAccount extends Page {
         this.errorField.style.display = 'none';
         this.nameField.value = '';
         this.typeField.value = 'wellsfargocsv';
+        this.journalField.value = '';
+        this.updateJournalField();
+
+        this._importersRequest = new APIRequestImporters().onData((importers) => {
+            this.typeField.innerHTML = '';
+            importers.forEach((importer) => {
+                const option = document.createElement('option');
+                option.value = importer['ID'];
+                option.textContent = importer['Name'];
+                this.typeField.appendChild(option);
+            });
+            this.updateJournalField();
+        }).run();
+    }
+
+    isJournal() {
+        // The journal importer's ID is completed with the
+        // name of the journal account to import.
+        return this.typeField.value.endsWith(':');
+    }
+
+    updateJournalField() {
+        const display = this.isJournal() ? 'inline-block' : 'none';
+        this.journalField.style.display = display;
+        this.journalBreak.style.display = display;
     }
 
     hide() {
@@ -112,8 +144,8 @@ class PageAccount extends Page {
         this.filters = new FilterEditorView('account-filters-section');
         this.transactions = new AccountTransactionsView();
 
-        this.upload.onUploaded = (transactions) => {
-            this.transactions.populateList(transactions);
+        this.upload.onUploaded = () => {
+            this.transactions.reload();
         };
         this.title.onClear = () => {
             this.transactions.populateList([]);
@@ -159,14 +159,25 @@ class AccountTransactionsView extends View {
         this.error = this.element.getElementsByClassName('error-message')[0];
         this.empty = this.element.getElementsByClassName('empty-list')[0];
         this.transactions = this.element.getElementsByClassName('transactions')[0];
+        this.moreButton = this.element.getElementsByClassName('transactions-more')[0];
+        this.moreButton.addEventListener('click', () => this.showMore());
 
         this._request = null;
+        this._accountID = null;
+        this._loaded = [];
+        this._cursor = '';
     }
 
     show(accountID) {
-        this._request = new APIRequestTransactions(accountID);
-        this._request.onData((transactions) => {
-            this.populateList(transactions);
+        // Transactions are loaded one page at a time, newest
+        // first, rather than all at once.
+        this._accountID = accountID;
+        this._loaded = [];
+        this._cursor = '';
+        this.moreButton.style.display = 'none';
+        this._request = new APIRequestTransactions(accountID, '');
+        this._request.onData((page) => {
+            this.addPage(page);
         }).runView(
             this.loader,
             this.error,
@@ -175,18 +186,43 @@ class AccountTransactionsView extends View {
         );
     }
 
+    showMore() {
+        this._request = new APIRequestTransactions(this._accountID, this._cursor);
+        this._request.onData((page) => {
+            this.addPage(page);
+        }).runView(
+            this.loader,
+            this.error,
+            null,
+            [this.moreButton],
+        );
+    }
+
     hide() {
         if (this._request) {
             this._request.cancel();
         }
     }
 
+    reload() {
+        this.hide();
+        this.show(this._accountID);
+    }
+
+    addPage(page) {
+        this._loaded = this._loaded.concat(page['Transactions'] || []);
+        this._cursor = page['NextCursor'];
+        this.populateList(this._loaded);
+        this.moreButton.style.display = this._cursor ? 'block' : 'none';
+    }
+
     populateList(transactions) {
         if (this._request) {
             // If some other view updates the transactions, we
             // don't want to continue requesting them.
             this._request.cancel();
         }
+        this.moreButton.style.display = 'none';
 
         if (transactions.length === 0) {
             this.empty.style.display = 'block';
@@ -195,7 +231,9 @@ class AccountTransactionsView extends View {
         }
         this.empty.style.display = 'none';
         this.transactions.style.display = 'table';
-        fillTransactionsTable(this.transactions, transactions);
+
+        // The table expects the oldest transactions first.
+        fillTransactionsTable(this.transactions, transactions.slice().reverse());
     }
 }
 
@@ -234,8 +272,15 @@ class AccountUploadView extends View {
         }
         const file = this.input.files[0];
         this._request = new APIRequestUploadTransactions(this._accountID, file);
-        this._request.onData((transactions) => {
-            this.onUploaded(transactions);
+        this._request.onData((result) => {
+            if (result['Dropped'] > 0) {
+                alert(result['Dropped'] + ' new transaction(s) were not imported because ' +
+                    'they fall within a reconciled period.');
+            }
+            if (result['TransferError']) {
+                alert('Transfer detection failed: ' + result['TransferError']);
+            }
+            this.onUploaded(result['Transactions']);
         }).runView(
             this.loader,
             this.error,
@@ -282,6 +327,7 @@ class FilterEditorView extends View {
 
         this._accountID = null;
         this._request = null;
+        this._filterData = {};
     }
 
     createSections() {
@@ -329,11 +375,12 @@ class FilterEditorView extends View {
     }
 
     save() {
-        const data = {
+        // Keep filters which the editor cannot display.
+        const data = Object.assign({}, this._filterData, {
             'PatternFilters': this.patternSection.save(),
             'CategoryFilters': this.categorySection.save(),
             'ReplaceFilters': this.replaceSection.save(),
-        };
+        });
 
         this._request = new APIRequestSetFilters(this._accountID, data);
         this._request.onData((filterData) => {
@@ -348,6 +395,7 @@ class FilterEditorView extends View {
     }
 
     loadFilterData(filterData) {
+        this._filterData = filterData;
         this.patternSection.load(filterData['PatternFilters']);
         this.categorySection.load(filterData['CategoryFilters']);
         this.replaceSection.load(filterData['ReplaceFilters']);
@@ -363,7 +411,7 @@ class SummaryView extends View {
         this.error = this.element.getElementsByClassName('error-message')[0];
 
         this.timespan = this.element.getElementsByClassName('summary-timespan')[0];
-        this.timespan.addEventListener('change', () => this.populateContent());
+        this.timespan.addEventListener('change', () => this.reload());
         this.totalIncome = this.element.getElementsByClassName('summary-total-income')[0];
         this.totalExpenses = this.element.getElementsByClassName('summary-total-expenses')[0];
         this.barGraph = this.element.getElementsByClassName('summary-content-bar-graph')[0];
@@ -376,7 +424,12 @@ class SummaryView extends View {
     }
 
     show() {
-        this._request = new APIRequestAllTransactions();
+        // Only the transactions in the selected timespan are
+        // requested, with their amounts converted to a single
+        // currency so that they can be totaled.
+        const start = new Date(this.firstTime());
+        const startDate = isFinite(start) ? formatAPIDate(start) : null;
+        this._request = new APIRequestAllTransactions(startDate, true);
         this._request.onData((transactions) => {
             this.content.style.display = 'block';
             this._data = transactions;
@@ -406,6 +459,17 @@ class SummaryView extends View {
             return;
         }
 
+        const firstDate = this.firstTime();
+        const transactions = this._data.filter((x) => {
+            // Transfers between accounts are not spending.
+            return new Date(x['Time']).getTime() >= firstDate && !x['TransferID'];
+        });
+
+        this.createBarGraph(transactions);
+        this.createUnknownTransactionTable(transactions);
+    }
+
+    firstTime() {
         const spanValue = this.timespan.value;
         let spanMillis = 24 * 60 * 60 * 1000;
         if (spanValue === 'days7') {
@@ -417,18 +481,12 @@ class SummaryView extends View {
         } else if (spanValue === 'all') {
             spanMillis = Infinity;
         }
-        const firstDate = new Date().getTime() - spanMillis;
-        const transactions = this._data.filter((x) => {
-            return new Date(x['Time']).getTime() >= firstDate;
-        });
-
-        this.createBarGraph(transactions);
-        this.createUnknownTransactionTable(transactions);
+        return new Date().getTime() - spanMillis;
     }
 
     createBarGraph(transactions) {
         const categories = {};
-        transactions.forEach((x) => {
+        expandSplits(transactions).forEach((x) => {
             categories[x['Category']] = (categories[x['Category']] || 0) + x['Amount'];
         });
         let totalAmount = 0;
@@ -531,6 +589,24 @@ function fillTransactionsTable(table, transactions) {
     });
 }
 
+function expandSplits(transactions) {
+    const result = [];
+    transactions.forEach((trans) => {
+        if (!trans['Splits'] || trans['Splits'].length === 0) {
+            result.push(trans);
+            return;
+        }
+        trans['Splits'].forEach((split) => {
+            result.push(Object.assign({}, trans, {
+                'Category': split['Category'],
+                'Amount': split['Amount'],
+                'Splits': null,
+            }));
+        });
+    });
+    return result;
+}
+
 function formatMoney(cents) {
     if (cents < 0) {
         return '-' + formatMoney(-cents);
@@ -538,6 +614,12 @@ function formatMoney(cents) {
     return '$' + (cents / 100).toFixed(2);
 }
 
+function formatAPIDate(date) {
+    const month = (1 + date.getMonth()).toString().padStart(2, '0');
+    const day = date.getDate().toString().padStart(2, '0');
+    return date.getFullYear() + '-' + month + '-' + day;
+}
+
 function formatDate(date) {
     // https://stackoverflow.com/questions/11591854/format-date-to-mm-dd-yyyy-in-javascript
     var year = date.getFullYear();
@@ -1,42 +1,58 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"reflect"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/unixpickle/essentials"
 	"github.com/unixpickle/pecunia/pecunia"
+	"github.com/unixpickle/pecunia/report"
 )
 
 type Server struct {
 	Storage pecunia.Storage
+
+	// BaseCurrency is the currency for totals of amounts
+	// in several currencies, unless one is requested.
+	BaseCurrency string
 }
 
 func main() {
 	var addr string
 	var assets string
 	var dataDir string
+	var baseCurrency string
 	flag.StringVar(&addr, "addr", ":8080", "address to listen on")
 	flag.StringVar(&assets, "assets", "./assets", "asset directory")
 	flag.StringVar(&dataDir, "data-dir", "pecunia_data", "directory to store data")
+	flag.StringVar(&baseCurrency, "currency", pecunia.DefaultCurrency,
+		"currency for totals of amounts in several currencies")
 	flag.Parse()
 
 	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
 		essentials.Must(os.Mkdir(dataDir, 0755))
 	}
 
-	server := &Server{Storage: &pecunia.DirStorage{Dir: dataDir}}
+	server := &Server{
+		Storage:      &pecunia.DirStorage{Dir: dataDir},
+		BaseCurrency: strings.ToUpper(baseCurrency),
+	}
 	fs := http.FileServer(http.Dir(assets))
 	http.Handle("/", fs)
 	http.HandleFunc("/accounts", DisableCache(server.ServeAccounts))
 	http.HandleFunc("/account", DisableCache(server.ServeAccount))
 	http.HandleFunc("/add_account", DisableCache(server.ServeAddAccount))
+	http.HandleFunc("/update_account", DisableCache(server.ServeUpdateAccount))
 	http.HandleFunc("/delete_account", DisableCache(server.ServeDeleteAccount))
 	http.HandleFunc("/clear_account", DisableCache(server.ServeClearAccount))
 	http.HandleFunc("/all_transactions", DisableCache(server.ServeAllTransactions))
@@ -46,6 +62,55 @@ func main() {
 	http.HandleFunc("/set_account_filters", DisableCache(server.ServeSetAccountFilters))
 	http.HandleFunc("/global_filters", DisableCache(server.ServeGlobalFilters))
 	http.HandleFunc("/set_global_filters", DisableCache(server.ServeSetGlobalFilters))
+	http.HandleFunc("/importers", DisableCache(server.ServeImporters))
+	http.HandleFunc("/importer_fields", DisableCache(server.ServeImporterFields))
+	http.HandleFunc("/overrides", DisableCache(server.ServeOverrides))
+	http.HandleFunc("/set_override", DisableCache(server.ServeSetOverride))
+	http.HandleFunc("/clear_override", DisableCache(server.ServeClearOverride))
+	http.HandleFunc("/set_splits", DisableCache(server.ServeSetSplits))
+	http.HandleFunc("/transfers", DisableCache(server.ServeTransfers))
+	http.HandleFunc("/detect_transfers", DisableCache(server.ServeDetectTransfers))
+	http.HandleFunc("/confirm_transfer", DisableCache(server.ServeConfirmTransfer))
+	http.HandleFunc("/unlink_transfer", DisableCache(server.ServeUnlinkTransfer))
+	http.HandleFunc("/transfer_settings", DisableCache(server.ServeTransferSettings))
+	http.HandleFunc("/set_transfer_settings", DisableCache(server.ServeSetTransferSettings))
+	http.HandleFunc("/merchants", DisableCache(server.ServeMerchants))
+	http.HandleFunc("/merchant_aliases", DisableCache(server.ServeMerchantAliases))
+	http.HandleFunc("/set_merchant_aliases", DisableCache(server.ServeSetMerchantAliases))
+	http.HandleFunc("/alerts", DisableCache(server.ServeAlerts))
+	http.HandleFunc("/acknowledge_alert", DisableCache(server.ServeAcknowledgeAlert))
+	http.HandleFunc("/dismiss_alert", DisableCache(server.ServeDismissAlert))
+	http.HandleFunc("/budgets", DisableCache(server.ServeBudgets))
+	http.HandleFunc("/set_budgets", DisableCache(server.ServeSetBudgets))
+	http.HandleFunc("/budget_status", DisableCache(server.ServeBudgetStatus))
+	http.HandleFunc("/recurring", DisableCache(server.ServeRecurring))
+	http.HandleFunc("/suggestions", DisableCache(server.ServeSuggestions))
+	http.HandleFunc("/accept_suggestion", DisableCache(server.ServeAcceptSuggestion))
+	http.HandleFunc("/report", DisableCache(server.ServeReport))
+	http.HandleFunc("/cash_flow", DisableCache(server.ServeCashFlow))
+	http.HandleFunc("/reconcile", DisableCache(server.ServeReconcile))
+	http.HandleFunc("/unreconcile", DisableCache(server.ServeUnreconcile))
+	http.HandleFunc("/exchange_rates", DisableCache(server.ServeExchangeRates))
+	http.HandleFunc("/upload_exchange_rates", DisableCache(server.ServeUploadExchangeRates))
+	http.HandleFunc("/balances", DisableCache(server.ServeBalances))
+	http.HandleFunc("/net_worth", DisableCache(server.ServeNetWorth))
+	http.HandleFunc("/forecast", DisableCache(server.ServeForecast))
+	http.HandleFunc("/search", DisableCache(server.ServeSearch))
+	http.HandleFunc("/export", DisableCache(server.ServeExport))
+	http.HandleFunc("/tax_settings", DisableCache(server.ServeTaxSettings))
+	http.HandleFunc("/set_tax_settings", DisableCache(server.ServeSetTaxSettings))
+	http.HandleFunc("/tax_report", DisableCache(server.ServeTaxReport))
+	http.HandleFunc("/statement", DisableCache(server.ServeStatement))
+	http.HandleFunc("/people", DisableCache(server.ServePeople))
+	http.HandleFunc("/set_people", DisableCache(server.ServeSetPeople))
+	http.HandleFunc("/share_rules", DisableCache(server.ServeShareRules))
+	http.HandleFunc("/set_share_rules", DisableCache(server.ServeSetShareRules))
+	http.HandleFunc("/settlements", DisableCache(server.ServeSettlements))
+	http.HandleFunc("/set_settlements", DisableCache(server.ServeSetSettlements))
+	http.HandleFunc("/add_settlement", DisableCache(server.ServeAddSettlement))
+	http.HandleFunc("/shared_balances", DisableCache(server.ServeSharedBalances))
+	http.HandleFunc("/export_rules", DisableCache(server.ServeExportRules))
+	http.HandleFunc("/import_rules", DisableCache(server.ServeImportRules))
 
 	essentials.Must(http.ListenAndServe(addr, nil))
 }
@@ -86,6 +151,58 @@ func (s *Server) ServeAddAccount(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) ServeUpdateAccount(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	account, err := pecunia.AccountForID(s.Storage, accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if name := r.FormValue("name"); name != "" {
+		account.Name = name
+	}
+	if kind := r.FormValue("kind"); kind != "" {
+		if kind != pecunia.AccountAsset && kind != pecunia.AccountLiability {
+			s.serveError(w, errors.New("unknown account kind: "+kind), http.StatusBadRequest)
+			return
+		}
+		account.Kind = kind
+	}
+	if currency := r.FormValue("currency"); currency != "" {
+		account.Currency = strings.ToUpper(currency)
+	}
+	if balance := r.FormValue("opening_balance"); balance != "" {
+		cents, err := strconv.Atoi(balance)
+		if err != nil {
+			s.serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		account.OpeningBalance = cents
+	}
+	if assertions := r.FormValue("balance_assertions"); assertions != "" {
+		account.BalanceAssertions = nil
+		if err := json.Unmarshal([]byte(assertions), &account.BalanceAssertions); err != nil {
+			s.serveError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	if _, ok := r.Form["owner_id"]; ok {
+		ownerID := r.FormValue("owner_id")
+		if ownerID != "" {
+			if _, err := s.findPerson(ownerID); err != nil {
+				s.serveError(w, err, http.StatusBadRequest)
+				return
+			}
+		}
+		account.OwnerID = ownerID
+	}
+	if err := s.Storage.UpdateAccount(account); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, account)
+	}
+}
+
 func (s *Server) ServeDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	accountID := r.FormValue("account_id")
 	if err := s.Storage.DeleteAccount(accountID); err != nil {
@@ -97,6 +214,15 @@ func (s *Server) ServeDeleteAccount(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) ServeClearAccount(w http.ResponseWriter, r *http.Request) {
 	accountID := r.FormValue("account_id")
+	existing, err := s.Storage.Transactions(accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if err := pecunia.CheckUnreconciled(existing); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
 	if err := s.Storage.SetTransactions(accountID, []*pecunia.Transaction{}); err != nil {
 		s.serveError(w, err, http.StatusInternalServerError)
 	} else {
@@ -105,41 +231,11 @@ func (s *Server) ServeClearAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) ServeAllTransactions(w http.ResponseWriter, r *http.Request) {
-	transactions := []*pecunia.Transaction{}
-	accts, err := s.Storage.Accounts()
-	if err != nil {
-		s.serveError(w, err, http.StatusInternalServerError)
-		return
-	}
-	for _, acct := range accts {
-		trans, err := s.Storage.Transactions(acct.ID)
-		if err != nil {
-			s.serveError(w, err, http.StatusInternalServerError)
-			return
-		}
-		filter, err := s.Storage.AccountFilters(acct.ID)
-		if err != nil {
-			s.serveError(w, err, http.StatusInternalServerError)
-			return
-		}
-		for t := range filter.Filter(pecunia.TransactionsToChan(trans)) {
-			transactions = append(transactions, t)
-		}
-	}
-	sort.SliceStable(transactions, func(i, j int) bool {
-		return transactions[i].Time.UnixNano() < transactions[j].Time.UnixNano()
-	})
-
-	globalFilters, err := s.Storage.GlobalFilters()
-	if err != nil {
+	if transactions, err := pecunia.AllTransactions(s.Storage); err != nil {
 		s.serveError(w, err, http.StatusInternalServerError)
-		return
+	} else {
+		s.serveTransactionList(w, r, transactions)
 	}
-	transactions = pecunia.TransactionsToSlice(
-		globalFilters.Filter(pecunia.TransactionsToChan(transactions)),
-	)
-
-	s.serveObject(w, transactions)
 }
 
 func (s *Server) ServeTransactions(w http.ResponseWriter, r *http.Request) {
@@ -147,7 +243,7 @@ func (s *Server) ServeTransactions(w http.ResponseWriter, r *http.Request) {
 	if trans, err := s.Storage.Transactions(accountID); err != nil {
 		s.serveError(w, err, http.StatusInternalServerError)
 	} else {
-		s.serveObject(w, trans)
+		s.serveTransactionList(w, r, trans)
 	}
 }
 
@@ -184,11 +280,59 @@ func (s *Server) ServeUploadTransactions(w http.ResponseWriter, r *http.Request)
 		s.serveError(w, err, http.StatusBadRequest)
 		return
 	}
+	trans, dropped := pecunia.LockReconciled(account, existing, trans)
 	if err := s.Storage.SetTransactions(accountID, trans); err != nil {
 		s.serveError(w, err, http.StatusInternalServerError)
 		return
 	}
-	s.serveObject(w, trans)
+
+	// The upload has already been saved, so a failure to
+	// detect transfers is reported alongside the result.
+	type uploadResult struct {
+		Transactions []*pecunia.Transaction
+
+		// The number of new transactions which were dropped
+		// because they fall within a reconciled period.
+		Dropped int
+
+		TransferError string
+	}
+	result := &uploadResult{Transactions: trans, Dropped: dropped}
+	if _, err := pecunia.UpdateTransfers(s.Storage); err != nil {
+		result.TransferError = err.Error()
+	}
+	s.serveObject(w, result)
+}
+
+func (s *Server) ServeImporters(w http.ResponseWriter, r *http.Request) {
+	type importerInfo struct {
+		ID   string
+		Name string
+	}
+	result := []*importerInfo{}
+	for _, importer := range pecunia.Importers() {
+		result = append(result, &importerInfo{ID: importer.ID(), Name: importer.Name()})
+	}
+	s.serveObject(w, result)
+}
+
+func (s *Server) ServeImporterFields(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	account, err := pecunia.AccountForID(s.Storage, accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	importer, err := pecunia.ImporterForID(account.ImporterID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if fi, ok := importer.(pecunia.FieldImporter); ok {
+		s.serveObject(w, fi.FieldNames())
+	} else {
+		s.serveObject(w, []string{})
+	}
 }
 
 func (s *Server) ServeAccountFilters(w http.ResponseWriter, r *http.Request) {
@@ -239,30 +383,1451 @@ func (s *Server) ServeSetGlobalFilters(w http.ResponseWriter, r *http.Request) {
 	s.serveObject(w, &filters)
 }
 
-func (s *Server) serveError(w http.ResponseWriter, err error, status int) {
-	w.Header().Set("content-type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":  err.Error(),
-		"status": status,
-		"result": nil,
-	})
+func (s *Server) ServeOverrides(w http.ResponseWriter, r *http.Request) {
+	if overrides, err := s.Storage.Overrides(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, overrides)
+	}
 }
 
-func (s *Server) serveObject(w http.ResponseWriter, obj interface{}) {
-	value := reflect.ValueOf(obj)
-	if value.Type().Kind() == reflect.Slice {
-		if value.Len() == 0 {
-			// Prevent null values in JSON object.
-			obj = []interface{}{}
+func (s *Server) ServeSetOverride(w http.ResponseWriter, r *http.Request) {
+	transactionID := r.FormValue("transaction_id")
+	if transactionID == "" {
+		s.serveError(w, errors.New("transaction_id is empty"), http.StatusBadRequest)
+		return
+	}
+	override := &pecunia.Override{
+		Category:    r.FormValue("category"),
+		Description: r.FormValue("description"),
+	}
+	for _, tag := range strings.Split(r.FormValue("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			override.Tags = append(override.Tags, tag)
 		}
 	}
-	w.Header().Set("content-type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"error":  nil,
-		"status": http.StatusOK,
-		"result": obj,
-	})
+	if _, err := s.findEditableTransaction(transactionID); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	overrides, err := s.Storage.Overrides()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if old, ok := overrides[transactionID]; ok {
+		override.Splits = old.Splits
+	}
+	if err := s.Storage.SetOverride(transactionID, override); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, override)
+	}
+}
+
+func (s *Server) ServeClearOverride(w http.ResponseWriter, r *http.Request) {
+	transactionID := r.FormValue("transaction_id")
+
+	// Stale overrides, such as those for transactions which
+	// were deleted or excluded by filters, may be cleared,
+	// so only a transaction which exists can be locked.
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, t := range transactions {
+		if t.ID == transactionID && t.Reconciled {
+			s.serveError(w, errors.New("transaction is reconciled: "+transactionID),
+				http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.Storage.SetOverride(transactionID, nil); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, "ok")
+	}
+}
+
+func (s *Server) ServeSetSplits(w http.ResponseWriter, r *http.Request) {
+	transactionID := r.FormValue("transaction_id")
+	var splits []*pecunia.Split
+	if err := json.Unmarshal([]byte(r.FormValue("splits")), &splits); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transaction, err := s.findEditableTransaction(transactionID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := pecunia.CheckSplits(transaction, splits); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	overrides, err := s.Storage.Overrides()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	override, ok := overrides[transactionID]
+	if !ok {
+		override = &pecunia.Override{}
+	}
+	override.Splits = splits
+	if err := s.Storage.SetOverride(transactionID, override); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, override)
+	}
+}
+
+func (s *Server) ServeTransfers(w http.ResponseWriter, r *http.Request) {
+	if transfers, err := s.Storage.Transfers(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, transfers)
+	}
+}
+
+func (s *Server) ServeDetectTransfers(w http.ResponseWriter, r *http.Request) {
+	if transfers, err := pecunia.UpdateTransfers(s.Storage); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, transfers)
+	}
+}
+
+func (s *Server) ServeConfirmTransfer(w http.ResponseWriter, r *http.Request) {
+	s.serveSetTransferStatus(w, r, pecunia.TransferConfirmed)
+}
+
+func (s *Server) ServeUnlinkTransfer(w http.ResponseWriter, r *http.Request) {
+	s.serveSetTransferStatus(w, r, pecunia.TransferUnlinked)
+}
+
+func (s *Server) serveSetTransferStatus(w http.ResponseWriter, r *http.Request, status string) {
+	transferID := r.FormValue("transfer_id")
+	if transfer, err := pecunia.SetTransferStatus(s.Storage, transferID, status); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+	} else {
+		s.serveObject(w, transfer)
+	}
+}
+
+func (s *Server) ServeTransferSettings(w http.ResponseWriter, r *http.Request) {
+	if settings, err := s.Storage.TransferSettings(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, settings)
+	}
+}
+
+func (s *Server) ServeSetTransferSettings(w http.ResponseWriter, r *http.Request) {
+	var settings pecunia.TransferSettings
+	if err := json.Unmarshal([]byte(r.FormValue("settings")), &settings); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if settings.WindowDays < 0 {
+		s.serveError(w, errors.New("window must be non-negative"), http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetTransferSettings(&settings); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, &settings)
+}
+
+func (s *Server) ServeMerchants(w http.ResponseWriter, r *http.Request) {
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions = pecunia.WithoutTransfers(transactions)
+	s.serveObject(w, pecunia.MerchantTotals(transactions))
+}
+
+func (s *Server) ServeMerchantAliases(w http.ResponseWriter, r *http.Request) {
+	if aliases, err := s.Storage.MerchantAliases(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, aliases)
+	}
+}
+
+func (s *Server) ServeSetMerchantAliases(w http.ResponseWriter, r *http.Request) {
+	var aliases []*pecunia.MerchantAlias
+	if err := json.Unmarshal([]byte(r.FormValue("aliases")), &aliases); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetMerchantAliases(aliases); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, aliases)
+}
+
+func (s *Server) ServeAlerts(w http.ResponseWriter, r *http.Request) {
+	settings := pecunia.DefaultAlertSettings()
+	if threshold := r.FormValue("new_merchant_threshold"); threshold != "" {
+		cents, err := strconv.Atoi(threshold)
+		if err != nil {
+			s.serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		settings.NewMerchantThreshold = cents
+	}
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	states, err := s.Storage.AlertStates()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions = pecunia.WithoutTransfers(transactions)
+	alerts := []*pecunia.Alert{}
+	for _, alert := range pecunia.DetectAlerts(transactions, settings, states) {
+		if alert.State != pecunia.AlertDismissed || r.FormValue("dismissed") == "1" {
+			alerts = append(alerts, alert)
+		}
+	}
+	s.serveObject(w, alerts)
+}
+
+func (s *Server) ServeAcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	s.serveSetAlertState(w, r, pecunia.AlertAcknowledged)
+}
+
+func (s *Server) ServeDismissAlert(w http.ResponseWriter, r *http.Request) {
+	s.serveSetAlertState(w, r, pecunia.AlertDismissed)
+}
+
+func (s *Server) serveSetAlertState(w http.ResponseWriter, r *http.Request, state string) {
+	alertID := r.FormValue("alert_id")
+	if alertID == "" {
+		s.serveError(w, errors.New("alert_id is empty"), http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetAlertState(alertID, state); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, "ok")
+	}
+}
+
+func (s *Server) ServeBudgets(w http.ResponseWriter, r *http.Request) {
+	if budgets, err := s.Storage.Budgets(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, budgets)
+	}
+}
+
+func (s *Server) ServeSetBudgets(w http.ResponseWriter, r *http.Request) {
+	var budgets []*pecunia.Budget
+	if err := json.Unmarshal([]byte(r.FormValue("budgets")), &budgets); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	for _, b := range budgets {
+		if b.Currency == "" {
+			b.Currency = s.BaseCurrency
+		}
+		b.Currency = strings.ToUpper(b.Currency)
+	}
+	if err := s.Storage.SetBudgets(budgets); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, budgets)
+}
+
+func (s *Server) ServeBudgetStatus(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	start, err := parseDate(r.FormValue("start"), now)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	end, err := parseDate(r.FormValue("end"), start.Add(time.Second))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// Each budget is tracked in its own currency, unless a
+	// currency is requested for all of them.
+	currency := strings.ToUpper(r.FormValue("currency"))
+	budgets, err := s.Storage.Budgets()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	rates, err := s.rateTable()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	allTransactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	allTransactions = pecunia.WithoutTransfers(allTransactions)
+	converted := map[string][]*pecunia.Transaction{}
+	periods := []*pecunia.BudgetPeriod{}
+	for _, b := range budgets {
+		if currency != "" {
+			if b, err = pecunia.ConvertBudget(b, rates, currency, start); err != nil {
+				s.serveError(w, err, http.StatusInternalServerError)
+				return
+			}
+		}
+		transactions, ok := converted[b.CurrencyOf()]
+		if !ok {
+			transactions, err = pecunia.ConvertTransactions(allTransactions, rates, b.CurrencyOf())
+			if err != nil {
+				s.serveError(w, err, http.StatusInternalServerError)
+				return
+			}
+			converted[b.CurrencyOf()] = transactions
+		}
+		periods = append(periods, pecunia.BudgetPeriods(b, transactions, start, end)...)
+	}
+	s.serveObject(w, periods)
+}
+
+func (s *Server) ServeRecurring(w http.ResponseWriter, r *http.Request) {
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions = pecunia.WithoutTransfers(transactions)
+	s.serveObject(w, pecunia.DetectRecurring(transactions, time.Now()))
+}
+
+func (s *Server) ServeSuggestions(w http.ResponseWriter, r *http.Request) {
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	classifier := pecunia.TrainClassifier(transactions)
+
+	type suggestions struct {
+		Transaction *pecunia.Transaction
+		Suggestions []*pecunia.Suggestion
+	}
+	results := []*suggestions{}
+	for _, t := range transactions {
+		if t.Category == "" {
+			results = append(results, &suggestions{
+				Transaction: t,
+				Suggestions: classifier.Suggest(t, 3),
+			})
+		}
+	}
+	s.serveObject(w, results)
+}
+
+func (s *Server) ServeAcceptSuggestion(w http.ResponseWriter, r *http.Request) {
+	transactionID := r.FormValue("transaction_id")
+	category := r.FormValue("category")
+	if category == "" {
+		s.serveError(w, errors.New("category is empty"), http.StatusBadRequest)
+		return
+	}
+	transaction, err := pecunia.GlobalFilterInput(s.Storage, transactionID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	filters, err := s.Storage.GlobalFilters()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	filter := pecunia.SuggestedFilter(transaction, category)
+	filters.CategoryFilters = append(filters.CategoryFilters, filter)
+
+	// Make sure the new filter categorizes the transaction
+	// it was created from, despite the other filters.
+	compiled, err := filters.Compile()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	filtered := compiled.Apply([]*pecunia.Transaction{transaction})
+	if len(filtered) != 1 || filtered[0].Category != category || len(filtered[0].Splits) > 0 {
+		s.serveError(w, errors.New("suggested filter would not categorize the transaction"),
+			http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetGlobalFilters(filters); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.serveObject(w, filter)
+}
+
+func (s *Server) ServeReport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.queryTransactions(query)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	groups, err := report.GroupBy(query.Apply(transactions), r.FormValue("group"))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, groups)
+}
+
+func (s *Server) ServeCashFlow(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	interval := r.FormValue("interval")
+	if interval == "" {
+		interval = report.ByMonth
+	}
+	transactions, err := s.queryTransactions(query)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Earlier transactions are needed to compare against
+	// previous periods.
+	start, end := query.Start, query.End
+	query.Start, query.End = time.Time{}, time.Time{}
+	periods, err := report.CashFlow(query.Apply(transactions), interval, start, end)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, periods)
+}
+
+func (s *Server) ServeExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if rates, err := s.Storage.ExchangeRates(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, rates)
+	}
+}
+
+func (s *Server) ServeUploadExchangeRates(w http.ResponseWriter, r *http.Request) {
+	r.ParseMultipartForm(2000000)
+
+	file, _, err := r.FormFile("document")
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	rates, err := pecunia.ReadExchangeRates(file)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("replace") != "1" {
+		existing, err := s.Storage.ExchangeRates()
+		if err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+		rates = append(existing, rates...)
+	}
+	if err := s.Storage.SetExchangeRates(rates); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.serveObject(w, rates)
+}
+
+func (s *Server) ServeReconcile(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	account, err := pecunia.AccountForID(s.Storage, accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	date, err := parseDate(r.FormValue("date"), time.Time{})
+	if err != nil || date.IsZero() {
+		s.serveError(w, errors.New("invalid or missing statement date"), http.StatusBadRequest)
+		return
+	}
+	balance, err := strconv.Atoi(r.FormValue("balance"))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.Storage.Transactions(accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	reconciliation, marked := pecunia.Reconcile(account, transactions, date, balance)
+	if reconciliation.Completed {
+		if err := s.Storage.SetTransactions(accountID, marked); err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+		account.Reconciliations = append(account.Reconciliations, reconciliation)
+		if err := s.Storage.UpdateAccount(account); err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	s.serveObject(w, reconciliation)
+}
+
+func (s *Server) ServeUnreconcile(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	account, err := pecunia.AccountForID(s.Storage, accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.Storage.Transactions(accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, t := range transactions {
+		t.Reconciled = false
+	}
+	if err := s.Storage.SetTransactions(accountID, transactions); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	account.Reconciliations = nil
+	if err := s.Storage.UpdateAccount(account); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.serveObject(w, account)
+}
+
+func (s *Server) ServeBalances(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	account, err := pecunia.AccountForID(s.Storage, accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.Storage.Transactions(accountID)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.serveObject(w, map[string]interface{}{
+		"Balances":   pecunia.RunningBalances(account, transactions),
+		"Assertions": pecunia.CheckAssertions(account, transactions),
+	})
+}
+
+func (s *Server) ServeNetWorth(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	interval := r.FormValue("interval")
+	if interval == "" {
+		interval = report.ByMonth
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions := map[string][]*pecunia.Transaction{}
+	for _, a := range accounts {
+		transactions[a.ID], err = s.Storage.Transactions(a.ID)
+		if err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	if query.Currency == "" {
+		currencies := map[string]bool{}
+		for _, a := range accounts {
+			currencies[a.CurrencyOf()] = true
+		}
+		query.Currency = s.reportCurrency(currencies)
+	}
+	rates, err := s.rateTable()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	points, err := report.NetWorth(accounts, transactions, interval, query.Start, query.End,
+		rates, query.Currency)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, points)
+}
+
+func (s *Server) ServeForecast(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	if date := r.FormValue("date"); date != "" {
+		day, err := parseDate(date, now)
+		if err != nil {
+			s.serveError(w, err, http.StatusBadRequest)
+			return
+		}
+		// Forecast from the end of the day, so that the
+		// day's own transactions count as spending so far.
+		now = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	allTransactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	query := &report.Query{
+		End:      now.Add(time.Nanosecond),
+		Currency: strings.ToUpper(r.FormValue("currency")),
+	}
+	transactions := query.Apply(allTransactions)
+	recurring := pecunia.DetectRecurring(transactions, now)
+
+	// Categories are totaled across accounts, so they use
+	// a single currency, while accounts keep their own.
+	converted, err := s.convertTransactions(allTransactions, query)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	categoryTransactions := query.Apply(converted)
+	categoryRecurring := pecunia.DetectRecurring(categoryTransactions, now)
+
+	budgets, err := s.convertedBudgets(query.Currency, now)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	accountTransactions := map[string][]*pecunia.Transaction{}
+	for _, a := range accounts {
+		accountTransactions[a.ID], err = s.Storage.Transactions(a.ID)
+		if err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	categories := report.ForecastCategories(categoryTransactions, categoryRecurring, budgets, now)
+	s.serveObject(w, map[string]interface{}{
+		"Currency":   query.Currency,
+		"Categories": categories,
+		"Accounts":   report.ForecastAccounts(accounts, accountTransactions, recurring, now),
+	})
+}
+
+func (s *Server) ServeExportRules(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	var filters *pecunia.MultiFilter
+	var err error
+	if accountID == "" {
+		filters, err = s.Storage.GlobalFilters()
+	} else {
+		filters, err = s.Storage.AccountFilters(accountID)
+	}
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := pecunia.WriteRules(&buf, filters); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/plain")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) ServeImportRules(w http.ResponseWriter, r *http.Request) {
+	accountID := r.FormValue("account_id")
+	filters, err := pecunia.ParseRules(strings.NewReader(r.FormValue("rules")))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if accountID == "" {
+		err = s.Storage.SetGlobalFilters(filters)
+	} else {
+		err = s.Storage.SetAccountFilters(accountID, filters)
+	}
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	s.serveObject(w, filters)
+}
+
+func (s *Server) ServeSearch(w http.ResponseWriter, r *http.Request) {
+	offset, err := parseInt(r.FormValue("offset"), 0)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	} else if offset < 0 {
+		s.serveError(w, errors.New("offset must be non-negative"), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseInt(r.FormValue("limit"), 100)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	search, err := report.ParseSearch(r.FormValue("q"), accounts)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.queryTransactions(&report.Query{
+		Currency: strings.ToUpper(r.FormValue("currency")),
+	})
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	matches, parts := search.Filter(transactions)
+	descending := r.FormValue("order") != "asc"
+	if err := report.SortTransactions(matches, r.FormValue("sort"), descending); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	totals := report.TotalTransactions(parts)
+	if offset > len(matches) {
+		offset = len(matches)
+	}
+	matches = matches[offset:]
+	if limit >= 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	s.serveObject(w, map[string]interface{}{
+		"Totals":       totals,
+		"Offset":       offset,
+		"Transactions": matches,
+	})
+}
+
+func (s *Server) ServeExport(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	allTransactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions := exportTransactions(allTransactions, query)
+
+	// The export is built in memory so that errors can be
+	// reported before anything is written.
+	var buf bytes.Buffer
+	var contentType, filename string
+	format := r.FormValue("format")
+	switch format {
+	case "csv":
+		contentType, filename = "text/csv", "transactions.csv"
+		err = pecunia.WriteCSV(&buf, transactions, accounts)
+	case "ndjson":
+		contentType, filename = "application/x-ndjson", "transactions.ndjson"
+		err = pecunia.WriteNDJSON(&buf, transactions)
+	case "ofx":
+		var statements []*pecunia.OFXStatement
+		for _, a := range accounts {
+			if len(query.AccountIDs) > 0 && !essentials.Contains(query.AccountIDs, a.ID) {
+				continue
+			}
+			rawTransactions, err := s.Storage.Transactions(a.ID)
+			if err != nil {
+				s.serveError(w, err, http.StatusInternalServerError)
+				return
+			}
+			statement := &pecunia.OFXStatement{Account: a, Start: query.Start, End: query.End}
+			for _, t := range transactions {
+				if t.AccountID == a.ID {
+					statement.Transactions = append(statement.Transactions, t)
+				}
+			}
+			if statement.End.IsZero() {
+				statement.End = time.Now()
+			}
+			if statement.Start.IsZero() {
+				statement.Start = statement.End
+				if len(statement.Transactions) > 0 {
+					statement.Start = statement.Transactions[0].Time
+				}
+			}
+			statement.Balance = pecunia.BalanceAt(a, rawTransactions, statement.End)
+			statements = append(statements, statement)
+		}
+		contentType, filename = "application/x-ofx", "transactions.ofx"
+		err = pecunia.WriteOFX(&buf, statements)
+	case "ledger":
+		contentType, filename = "text/plain", "transactions.journal"
+		err = pecunia.WriteLedger(&buf, accounts, transactions, allTransactions)
+	case "beancount":
+		contentType, filename = "text/plain", "transactions.beancount"
+		err = pecunia.WriteBeancount(&buf, accounts, transactions, allTransactions)
+	default:
+		s.serveError(w, errors.New("unknown export format: "+format), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) ServeTaxSettings(w http.ResponseWriter, r *http.Request) {
+	if settings, err := s.Storage.TaxSettings(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, settings)
+	}
+}
+
+func (s *Server) ServeSetTaxSettings(w http.ResponseWriter, r *http.Request) {
+	settings := pecunia.DefaultTaxSettings()
+	if err := json.Unmarshal([]byte(r.FormValue("settings")), settings); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetTaxSettings(settings); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, settings)
+}
+
+func (s *Server) ServeTaxReport(w http.ResponseWriter, r *http.Request) {
+	year, err := parseInt(r.FormValue("year"), time.Now().Year())
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	settings, err := s.Storage.TaxSettings()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	transactions, err := s.queryTransactions(&report.Query{
+		Currency: strings.ToUpper(r.FormValue("currency")),
+	})
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	taxReport := report.TaxYear(transactions, settings, year)
+	if r.FormValue("format") != "csv" {
+		s.serveObject(w, taxReport)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/csv")
+	w.Header().Set("content-disposition", fmt.Sprintf(`attachment; filename="tax_%d.csv"`, year))
+	taxReport.WriteCSV(w, accounts)
+}
+
+func (s *Server) ServeStatement(w http.ResponseWriter, r *http.Request) {
+	query := &report.Query{Currency: strings.ToUpper(r.FormValue("currency"))}
+	transactions, err := s.queryTransactions(query)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	t := time.Now()
+	year := r.FormValue("year")
+	if year != "" {
+		t, err = time.ParseInLocation("2006", year, time.Local)
+		if err != nil {
+			s.serveError(w, errors.New("year must be YYYY"), http.StatusBadRequest)
+			return
+		}
+	} else if month := r.FormValue("month"); month != "" {
+		t, err = time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			s.serveError(w, errors.New("month must be YYYY-MM"), http.StatusBadRequest)
+			return
+		}
+	}
+	budgets, err := s.convertedBudgets(query.Currency, t)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var statement *report.Statement
+	if year != "" {
+		statement = report.YearStatement(transactions, budgets, t)
+	} else {
+		statement = report.MonthStatement(transactions, budgets, t)
+	}
+	statement.Currency = query.Currency
+
+	var buf bytes.Buffer
+	if err := statement.WriteHTML(&buf, accounts); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) ServePeople(w http.ResponseWriter, r *http.Request) {
+	if people, err := s.Storage.People(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, people)
+	}
+}
+
+func (s *Server) ServeSetPeople(w http.ResponseWriter, r *http.Request) {
+	var people []*pecunia.Person
+	if err := json.Unmarshal([]byte(r.FormValue("people")), &people); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetPeople(people); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, people)
+}
+
+func (s *Server) ServeShareRules(w http.ResponseWriter, r *http.Request) {
+	if rules, err := s.Storage.ShareRules(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, rules)
+	}
+}
+
+func (s *Server) ServeSetShareRules(w http.ResponseWriter, r *http.Request) {
+	var rules []*pecunia.ShareRule
+	if err := json.Unmarshal([]byte(r.FormValue("rules")), &rules); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if err := s.Storage.SetShareRules(rules); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, rules)
+}
+
+func (s *Server) ServeSettlements(w http.ResponseWriter, r *http.Request) {
+	if settlements, err := s.Storage.Settlements(); err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+	} else {
+		s.serveObject(w, settlements)
+	}
+}
+
+func (s *Server) ServeSetSettlements(w http.ResponseWriter, r *http.Request) {
+	var settlements []*pecunia.Settlement
+	if err := json.Unmarshal([]byte(r.FormValue("settlements")), &settlements); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	for _, settlement := range settlements {
+		if settlement.Currency == "" {
+			settlement.Currency = s.BaseCurrency
+		}
+		settlement.Currency = strings.ToUpper(settlement.Currency)
+	}
+	if err := s.Storage.SetSettlements(settlements); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, settlements)
+}
+
+func (s *Server) ServeAddSettlement(w http.ResponseWriter, r *http.Request) {
+	date, err := parseDate(r.FormValue("date"), time.Now())
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	amount, err := strconv.Atoi(r.FormValue("amount"))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	currency := strings.ToUpper(r.FormValue("currency"))
+	if currency == "" {
+		currency = s.BaseCurrency
+	}
+	settlement := &pecunia.Settlement{
+		Time:     date,
+		FromID:   r.FormValue("from_id"),
+		ToID:     r.FormValue("to_id"),
+		Amount:   amount,
+		Currency: currency,
+		Note:     r.FormValue("note"),
+	}
+	if err := s.Storage.AddSettlement(settlement); err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.serveObject(w, settlement)
+}
+
+func (s *Server) ServeSharedBalances(w http.ResponseWriter, r *http.Request) {
+	query, err := parseQuery(r)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	transactions, err := s.queryTransactions(query)
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	accounts, err := s.Storage.Accounts()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	rules, err := s.Storage.ShareRules()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	settlements, err := s.Storage.Settlements()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var selected []*pecunia.Transaction
+	for _, t := range transactions {
+		if query.Match(t) {
+			selected = append(selected, t)
+		}
+	}
+	rates, err := s.rateTable()
+	if err != nil {
+		s.serveError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var selectedSettlements []*pecunia.Settlement
+	for _, settlement := range settlements {
+		if (query.Start.IsZero() || !settlement.Time.Before(query.Start)) &&
+			(query.End.IsZero() || settlement.Time.Before(query.End)) {
+			converted := *settlement
+			converted.Currency = query.Currency
+			converted.Amount, err = rates.Convert(settlement.Amount, settlement.CurrencyOf(),
+				query.Currency, settlement.Time)
+			if err != nil {
+				s.serveError(w, err, http.StatusInternalServerError)
+				return
+			}
+			selectedSettlements = append(selectedSettlements, &converted)
+		}
+	}
+	s.serveObject(w, pecunia.SharedBalances(selected, accounts, rules, selectedSettlements))
+}
+
+// queryTransactions gets all of the filtered transactions,
+// converted to the currency of the query.
+//
+// If the query has no currency, it is set to the currency
+// of every transaction if they share one, or otherwise the
+// base currency, so that totals never mix currencies.
+func (s *Server) queryTransactions(q *report.Query) ([]*pecunia.Transaction, error) {
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return s.convertTransactions(transactions, q)
+}
+
+// convertTransactions converts transactions like
+// queryTransactions.
+func (s *Server) convertTransactions(transactions []*pecunia.Transaction,
+	q *report.Query) ([]*pecunia.Transaction, error) {
+	if q.Currency == "" {
+		currencies := map[string]bool{}
+		for _, t := range transactions {
+			currencies[t.CurrencyOf()] = true
+		}
+		q.Currency = s.reportCurrency(currencies)
+	}
+	rates, err := s.rateTable()
+	if err != nil {
+		return nil, err
+	}
+	return pecunia.ConvertTransactions(transactions, rates, q.Currency)
+}
+
+// reportCurrency picks the currency to total amounts in
+// when none is requested. This is the only currency in a
+// set of currencies, or the base currency if there are
+// several.
+func (s *Server) reportCurrency(currencies map[string]bool) string {
+	if len(currencies) == 1 {
+		for currency := range currencies {
+			return currency
+		}
+	}
+	return s.BaseCurrency
+}
+
+// convertedBudgets gets all of the budgets, with their
+// limits converted to a currency at the rates as of date.
+func (s *Server) convertedBudgets(currency string, date time.Time) ([]*pecunia.Budget, error) {
+	budgets, err := s.Storage.Budgets()
+	if err != nil {
+		return nil, err
+	}
+	rates, err := s.rateTable()
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range budgets {
+		if budgets[i], err = pecunia.ConvertBudget(b, rates, currency, date); err != nil {
+			return nil, err
+		}
+	}
+	return budgets, nil
+}
+
+func (s *Server) rateTable() (*pecunia.RateTable, error) {
+	rates, err := s.Storage.ExchangeRates()
+	if err != nil {
+		return nil, err
+	}
+	return pecunia.NewRateTable(rates), nil
+}
+
+func (s *Server) findPerson(id string) (*pecunia.Person, error) {
+	people, err := s.Storage.People()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range people {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, errors.New("no person with ID: " + id)
+}
+
+func (s *Server) findTransaction(id string) (*pecunia.Transaction, error) {
+	transactions, err := pecunia.AllTransactions(s.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return nil, errors.New("no transaction with ID: " + id)
+}
+
+// findEditableTransaction is like findTransaction, but
+// fails if the transaction is reconciled and therefore
+// locked against edits.
+func (s *Server) findEditableTransaction(id string) (*pecunia.Transaction, error) {
+	t, err := s.findTransaction(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.Reconciled {
+		return nil, errors.New("transaction is reconciled: " + id)
+	}
+	return t, nil
+}
+
+func (s *Server) serveError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  err.Error(),
+		"status": status,
+		"result": nil,
+	})
+}
+
+func (s *Server) serveObject(w http.ResponseWriter, obj interface{}) {
+	value := reflect.ValueOf(obj)
+	if value.Type().Kind() == reflect.Slice {
+		if value.Len() == 0 {
+			// Prevent null values in JSON object.
+			obj = []interface{}{}
+		}
+	}
+	w.Header().Set("content-type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  nil,
+		"status": http.StatusOK,
+		"result": obj,
+	})
+}
+
+// serveTransactionList serves a list of transactions,
+// restricted to the request's start and end dates. The
+// original order is kept unless the request gives a sort
+// order or asks for a page.
+//
+// If a limit is given, one page is served along with a
+// cursor for the next page. Otherwise, the whole list is
+// streamed.
+func (s *Server) serveTransactionList(w http.ResponseWriter, r *http.Request,
+	transactions []*pecunia.Transaction) {
+	currency := strings.ToUpper(r.FormValue("currency"))
+	if currency != "" || r.FormValue("convert") == "1" {
+		var err error
+		transactions, err = s.convertTransactions(transactions, &report.Query{Currency: currency})
+		if err != nil {
+			s.serveError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+	start, err := parseDate(r.FormValue("start"), time.Time{})
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	end, err := parseDate(r.FormValue("end"), time.Time{})
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	limit, err := parseInt(r.FormValue("limit"), 0)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	sortField := r.FormValue("sort")
+	if sortField == "" {
+		sortField = report.SortTime
+	}
+	// Like search results, lists are newest first unless
+	// requested otherwise.
+	descending := r.FormValue("order") != "asc"
+	fields, err := parseFields(r.FormValue("fields"))
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	selected := []*pecunia.Transaction{}
+	for _, t := range transactions {
+		if (start.IsZero() || !t.Time.Before(start)) && (end.IsZero() || t.Time.Before(end)) {
+			selected = append(selected, t)
+		}
+	}
+	paged := limit > 0 || r.FormValue("cursor") != ""
+	if paged || r.FormValue("sort") != "" || r.FormValue("order") != "" {
+		if err := report.SortTransactions(selected, sortField, descending); err != nil {
+			s.serveError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	if !paged {
+		s.streamTransactions(w, selected, fields)
+		return
+	}
+	page, err := report.Paginate(selected, sortField, descending, r.FormValue("cursor"), limit)
+	if err != nil {
+		s.serveError(w, err, http.StatusBadRequest)
+		return
+	}
+	if fields == nil {
+		s.serveObject(w, page)
+		return
+	}
+	results := []map[string]interface{}{}
+	for _, t := range page.Transactions {
+		results = append(results, selectFields(t, fields))
+	}
+	s.serveObject(w, map[string]interface{}{
+		"Transactions": results,
+		"NextCursor":   page.NextCursor,
+	})
+}
+
+// streamTransactions serves a list of transactions like
+// serveObject, but encodes the transactions one at a time
+// rather than building the whole response in memory.
+//
+// If fields is non-nil, only those fields are included.
+func (s *Server) streamTransactions(w http.ResponseWriter, transactions []*pecunia.Transaction,
+	fields []string) {
+	w.Header().Set("content-type", "application/json")
+	buf := bufio.NewWriter(w)
+	defer buf.Flush()
+	fmt.Fprintf(buf, `{"error":null,"status":%d,"result":[`, http.StatusOK)
+	enc := json.NewEncoder(buf)
+	for i, t := range transactions {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		var obj interface{} = t
+		if fields != nil {
+			obj = selectFields(t, fields)
+		}
+		if err := enc.Encode(obj); err != nil {
+			// The status has already been sent, so the
+			// best we can do is end the response early.
+			return
+		}
+	}
+	buf.WriteString("]}\n")
+}
+
+// parseFields parses a comma-separated list of
+// Transaction fields, or returns nil if the list is empty.
+func parseFields(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var res []string
+	transactionType := reflect.TypeOf(pecunia.Transaction{})
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if field, ok := transactionType.FieldByName(name); !ok || field.Tag.Get("json") == "-" {
+			return nil, errors.New("unknown transaction field: " + name)
+		}
+		res = append(res, name)
+	}
+	return res, nil
+}
+
+// selectFields creates a JSON object with only some of the
+// fields of a transaction.
+func selectFields(t *pecunia.Transaction, fields []string) map[string]interface{} {
+	value := reflect.ValueOf(t).Elem()
+	res := map[string]interface{}{}
+	for _, name := range fields {
+		res[name] = value.FieldByName(name).Interface()
+	}
+	return res
+}
+
+// parseQuery reads the common report parameters from a
+// request: start and end dates, any number of account_id
+// values, a sign, and whether to include transfers.
+func parseQuery(r *http.Request) (*report.Query, error) {
+	r.ParseForm()
+	var query report.Query
+	var err error
+	if query.Start, err = parseDate(r.FormValue("start"), time.Time{}); err != nil {
+		return nil, err
+	}
+	if query.End, err = parseDate(r.FormValue("end"), time.Time{}); err != nil {
+		return nil, err
+	}
+	for _, id := range r.Form["account_id"] {
+		if id != "" {
+			query.AccountIDs = append(query.AccountIDs, id)
+		}
+	}
+	switch r.FormValue("sign") {
+	case "":
+	case "positive":
+		query.Sign = 1
+	case "negative":
+		query.Sign = -1
+	default:
+		return nil, errors.New("sign must be positive or negative")
+	}
+	query.IncludeTransfers = r.FormValue("transfers") == "1"
+	query.Currency = strings.ToUpper(r.FormValue("currency"))
+	return &query, nil
+}
+
+// exportTransactions selects the transactions matching the
+// date range and accounts of a query. Unlike Query.Apply,
+// splits are not expanded and transfers are kept, since an
+// export should include everything in each account.
+func exportTransactions(ts []*pecunia.Transaction, q *report.Query) []*pecunia.Transaction {
+	exportQuery := *q
+	exportQuery.IncludeTransfers = true
+	var res []*pecunia.Transaction
+	for _, t := range ts {
+		if exportQuery.Match(t) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// parseInt parses an integer, or returns a default value
+// for an empty string.
+func parseInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseDate parses a YYYY-MM-DD date in the local time
+// zone, or returns a default value for an empty string.
+func parseDate(value string, defaultValue time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
 }
 
 func DisableCache(f http.HandlerFunc) http.HandlerFunc {
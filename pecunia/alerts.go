@@ -0,0 +1,170 @@
+package pecunia
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Kinds of alerts.
+const (
+	AlertOutlier     = "outlier"
+	AlertNewMerchant = "new_merchant"
+	AlertDuplicate   = "duplicate"
+)
+
+// States of alerts.
+const (
+	AlertNew          = ""
+	AlertAcknowledged = "acknowledged"
+	AlertDismissed    = "dismissed"
+)
+
+// An Alert flags one or more transactions which look
+// unusual.
+type Alert struct {
+	// ID is derived from the kind and the transactions, so
+	// it is the same every time the alert is detected.
+	// Outliers also include whether the merchant or the
+	// category was unusual, since a transaction may be an
+	// outlier for both.
+	ID     string
+	Kind   string
+	State  string
+	Reason string
+
+	Transactions []*Transaction
+}
+
+// AlertSettings controls which transactions are flagged
+// by DetectAlerts.
+type AlertSettings struct {
+	// The number of standard deviations from the mean of
+	// a category or merchant for an amount to be flagged.
+	OutlierStdDevs float64
+
+	// The minimum number of earlier transactions in a
+	// category or merchant before outliers are flagged.
+	OutlierMinHistory int
+
+	// The minimum absolute amount, in cents, for a first
+	// transaction with a merchant to be flagged.
+	NewMerchantThreshold int
+
+	// The maximum time between two identical charges for
+	// them to be considered a possible double charge.
+	DuplicateWindow time.Duration
+}
+
+// DefaultAlertSettings returns reasonable AlertSettings.
+func DefaultAlertSettings() *AlertSettings {
+	return &AlertSettings{
+		OutlierStdDevs:       3,
+		OutlierMinHistory:    5,
+		NewMerchantThreshold: 10000,
+		DuplicateWindow:      24 * time.Hour,
+	}
+}
+
+// DetectAlerts finds unusual transactions.
+//
+// Each transaction is only compared to the transactions
+// before it, so importing newer transactions does not
+// change the existing alerts. However, importing older
+// history may add or remove alerts, since it changes the
+// statistics and the first transaction of each merchant.
+// The states of the alerts are filled in from states,
+// which maps alert IDs to states.
+func DetectAlerts(ts []*Transaction, settings *AlertSettings,
+	states map[string]string) []*Alert {
+	ts = append([]*Transaction{}, ts...)
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Time.Before(ts[j].Time)
+	})
+
+	var res []*Alert
+	addAlert := func(kind, scope, reason string, ts ...*Transaction) {
+		id := kind
+		if scope != "" {
+			id += ":" + scope
+		}
+		for _, t := range ts {
+			id += ":" + t.ID
+		}
+		res = append(res, &Alert{
+			ID:           id,
+			Kind:         kind,
+			State:        states[id],
+			Reason:       reason,
+			Transactions: ts,
+		})
+	}
+
+	categoryStats := map[string]*runningStats{}
+	merchantStats := map[string]*runningStats{}
+	lastByCharge := map[string]*Transaction{}
+	for _, t := range ts {
+		if t.Amount >= 0 {
+			continue
+		}
+		amount := float64(-t.Amount)
+
+		if t.Merchant != "" {
+			chargeKey := fmt.Sprintf("%s\x00%s\x00%d", t.AccountID, t.Merchant, t.Amount)
+			if last, ok := lastByCharge[chargeKey]; ok &&
+				t.Time.Sub(last.Time) <= settings.DuplicateWindow {
+				addAlert(AlertDuplicate, "", "possible double charge from "+t.Merchant, last, t)
+			}
+			lastByCharge[chargeKey] = t
+
+			stats := merchantStats[t.Merchant]
+			if stats == nil {
+				stats = &runningStats{}
+				merchantStats[t.Merchant] = stats
+				if essentials.AbsInt(t.Amount) >= settings.NewMerchantThreshold {
+					addAlert(AlertNewMerchant, "", "first transaction with "+t.Merchant, t)
+				}
+			} else if stats.IsOutlier(amount, settings) {
+				addAlert(AlertOutlier, "merchant", "unusual amount for "+t.Merchant, t)
+			}
+			stats.Add(amount)
+		}
+
+		if t.Category != "" {
+			stats := categoryStats[t.Category]
+			if stats == nil {
+				stats = &runningStats{}
+				categoryStats[t.Category] = stats
+			} else if stats.IsOutlier(amount, settings) {
+				addAlert(AlertOutlier, "category", "unusual amount for category "+t.Category, t)
+			}
+			stats.Add(amount)
+		}
+	}
+
+	return res
+}
+
+type runningStats struct {
+	Count int
+	Sum   float64
+	SqSum float64
+}
+
+func (r *runningStats) Add(x float64) {
+	r.Count++
+	r.Sum += x
+	r.SqSum += x * x
+}
+
+func (r *runningStats) IsOutlier(x float64, settings *AlertSettings) bool {
+	if r.Count < settings.OutlierMinHistory {
+		return false
+	}
+	mean := r.Sum / float64(r.Count)
+	variance := math.Max(0, r.SqSum/float64(r.Count)-mean*mean)
+	return math.Abs(x-mean) > settings.OutlierStdDevs*math.Sqrt(variance) && x > mean*1.5
+}
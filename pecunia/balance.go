@@ -0,0 +1,95 @@
+package pecunia
+
+import (
+	"sort"
+	"time"
+)
+
+// Kinds of accounts.
+const (
+	AccountAsset     = "asset"
+	AccountLiability = "liability"
+)
+
+// A BalanceAssertion records the balance of an account at
+// a point in time, such as from a bank statement.
+type BalanceAssertion struct {
+	Time    time.Time
+	Balance int
+}
+
+// A BalanceEntry is a transaction along with the balance
+// of its account right after the transaction.
+type BalanceEntry struct {
+	Transaction *Transaction
+	Balance     int
+}
+
+// An AssertionResult compares a balance assertion to the
+// balance computed from an account's transactions.
+type AssertionResult struct {
+	Assertion  *BalanceAssertion
+	Computed   int
+	Difference int
+}
+
+// IsLiability checks if the account is a liability, such
+// as a loan or a credit card.
+func (a *Account) IsLiability() bool {
+	return a.Kind == AccountLiability
+}
+
+// RunningBalances computes the balance of an account after
+// each of its transactions, in chronological order.
+//
+// The transactions should be the account's unfiltered
+// transactions, since filters may exclude transactions
+// which still affect the balance.
+func RunningBalances(a *Account, ts []*Transaction) []*BalanceEntry {
+	ts = sortedByTime(ts)
+	res := make([]*BalanceEntry, len(ts))
+	balance := a.OpeningBalance
+	for i, t := range ts {
+		balance += t.Amount
+		res[i] = &BalanceEntry{Transaction: t, Balance: balance}
+	}
+	return res
+}
+
+// BalanceAt computes the balance of an account including
+// every transaction before time t.
+func BalanceAt(a *Account, ts []*Transaction, t time.Time) int {
+	balance := a.OpeningBalance
+	for _, tr := range ts {
+		if tr.Time.Before(t) {
+			balance += tr.Amount
+		}
+	}
+	return balance
+}
+
+// CheckAssertions compares each of an account's balance
+// assertions to the balance computed from transactions.
+//
+// An assertion covers every transaction up to and
+// including its time.
+func CheckAssertions(a *Account, ts []*Transaction) []*AssertionResult {
+	res := make([]*AssertionResult, len(a.BalanceAssertions))
+	for i, assertion := range a.BalanceAssertions {
+		computed := BalanceAt(a, ts, assertion.Time.Add(time.Nanosecond))
+		res[i] = &AssertionResult{
+			Assertion:  assertion,
+			Computed:   computed,
+			Difference: assertion.Balance - computed,
+		}
+	}
+	return res
+}
+
+func sortedByTime(ts []*Transaction) []*Transaction {
+	ts = append([]*Transaction{}, ts...)
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Time.UnixNano() < ts[j].Time.UnixNano()
+	})
+	return ts
+}
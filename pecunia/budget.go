@@ -0,0 +1,147 @@
+package pecunia
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// A Budget limits the spending in a category over each
+// week, month, or year.
+type Budget struct {
+	// Set by the data store.
+	ID string
+
+	Category string
+
+	// One of IntervalWeekly, IntervalMonthly, or
+	// IntervalYearly.
+	Period string
+
+	// The maximum spending per period, in hundredths of a
+	// unit of Currency.
+	Limit int
+
+	// Currency is the currency of Limit. If empty,
+	// DefaultCurrency is used.
+	Currency string
+
+	// If set, unspent money from a period is added to the
+	// limit of the next period, and overspending is taken
+	// out of it.
+	Rollover bool
+
+	// The beginning of the first period.
+	Start time.Time
+}
+
+// Check makes sure the budget is valid.
+func (b *Budget) Check() error {
+	if b.Category == "" {
+		return errors.New("budget has no category")
+	}
+	if b.Period != IntervalWeekly && b.Period != IntervalMonthly && b.Period != IntervalYearly {
+		return fmt.Errorf("unknown budget period: %s", b.Period)
+	}
+	if b.Limit < 0 {
+		return errors.New("budget limit must be non-negative")
+	}
+	if b.Start.IsZero() {
+		return errors.New("budget has no start date")
+	}
+	return nil
+}
+
+// A BudgetPeriod compares the spending for one period of
+// a budget to its limit.
+type BudgetPeriod struct {
+	BudgetID string
+	Category string
+	Currency string
+
+	Start time.Time
+	End   time.Time
+
+	// The limit, including anything rolled over from the
+	// previous periods.
+	Limit int
+
+	Spent     int
+	Remaining int
+}
+
+// BudgetPeriods computes the status of a budget for every
+// period that overlaps the time range [start, end).
+//
+// The transactions should not include transfers, and they
+// should be converted to the budget's currency. Spending
+// is the negated total of the budget's category, so
+// refunds count against spending.
+func BudgetPeriods(b *Budget, ts []*Transaction, start, end time.Time) []*BudgetPeriod {
+	spending := map[int]int{}
+	for _, t := range ExpandSplits(ts) {
+		if t.Category == b.Category && !t.Time.Before(b.Start) && t.Time.Before(end) {
+			spending[b.periodIndex(t.Time)] -= t.Amount
+		}
+	}
+
+	var res []*BudgetPeriod
+	var rollover int
+	for i := 0; ; i++ {
+		periodStart, periodEnd := b.periodBounds(i)
+		if !periodStart.Before(end) {
+			break
+		}
+		limit := b.Limit + rollover
+		remaining := limit - spending[i]
+		if b.Rollover {
+			rollover = remaining
+		}
+		if periodEnd.After(start) {
+			res = append(res, &BudgetPeriod{
+				BudgetID:  b.ID,
+				Category:  b.Category,
+				Currency:  b.CurrencyOf(),
+				Start:     periodStart,
+				End:       periodEnd,
+				Limit:     limit,
+				Spent:     spending[i],
+				Remaining: remaining,
+			})
+		}
+	}
+	return res
+}
+
+func (b *Budget) periodBounds(i int) (time.Time, time.Time) {
+	return b.periodStart(i), b.periodStart(i + 1)
+}
+
+// periodStart gets the start of the i-th period. Monthly
+// and yearly periods starting late in a month are clamped
+// to the end of shorter months, so a budget starting on
+// January 31st has a period starting on February 28th.
+func (b *Budget) periodStart(i int) time.Time {
+	return addIntervals(b.Start, b.Period, i)
+}
+
+func (b *Budget) periodIndex(t time.Time) int {
+	// Estimate the index, then correct it, since months
+	// and years vary in length.
+	var idx int
+	switch b.Period {
+	case IntervalWeekly:
+		idx = int(t.Sub(b.Start).Hours() / (24 * 7))
+	case IntervalMonthly:
+		idx = (t.Year()-b.Start.Year())*12 + int(t.Month()-b.Start.Month())
+	default:
+		idx = t.Year() - b.Start.Year()
+	}
+	for idx > 0 && b.periodStart(idx).After(t) {
+		idx--
+	}
+	for !b.periodStart(idx + 1).After(t) {
+		idx++
+	}
+	return idx
+}
@@ -0,0 +1,42 @@
+package pecunia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetPeriodsMonthEnd(t *testing.T) {
+	b := &Budget{
+		Category: "Rent",
+		Period:   IntervalMonthly,
+		Limit:    100000,
+		Start:    time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+	ts := []*Transaction{
+		{Time: time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC), Amount: -1000, Category: "Rent"},
+		{Time: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Amount: -2000, Category: "Rent"},
+		{Time: time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC), Amount: -4000, Category: "Rent"},
+	}
+	periods := BudgetPeriods(b, ts, b.Start, time.Date(2026, 4, 30, 0, 0, 0, 0, time.UTC))
+
+	expected := []struct {
+		Start time.Time
+		Spent int
+	}{
+		{time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), 1000},
+		{time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC), 6000},
+		{time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), 0},
+	}
+	if len(periods) != len(expected) {
+		t.Fatalf("expected %d periods but got %d", len(expected), len(periods))
+	}
+	for i, p := range periods {
+		if !p.Start.Equal(expected[i].Start) || p.Spent != expected[i].Spent {
+			t.Errorf("period %d: expected start %v and spending %d but got %v and %d",
+				i, expected[i].Start, expected[i].Spent, p.Start, p.Spent)
+		}
+		if !p.End.After(p.Start) {
+			t.Errorf("period %d: end %v is not after start %v", i, p.End, p.Start)
+		}
+	}
+}
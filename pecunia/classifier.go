@@ -0,0 +1,163 @@
+package pecunia
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Suggestion is a possible category for a transaction.
+type Suggestion struct {
+	Category    string
+	Probability float64
+}
+
+// A Classifier is a naive Bayes model which predicts the
+// categories of transactions from their description words
+// and approximate amounts.
+type Classifier struct {
+	// Number of training transactions per category.
+	CategoryCounts map[string]int
+
+	// Number of occurrences of each feature per category.
+	FeatureCounts map[string]map[string]int
+
+	// Total number of features seen per category.
+	FeatureTotals map[string]int
+
+	// All of the features seen during training.
+	Vocabulary map[string]bool
+}
+
+// TrainClassifier creates a Classifier from the already
+// categorized transactions in ts.
+// Uncategorized transactions are ignored.
+func TrainClassifier(ts []*Transaction) *Classifier {
+	c := &Classifier{
+		CategoryCounts: map[string]int{},
+		FeatureCounts:  map[string]map[string]int{},
+		FeatureTotals:  map[string]int{},
+		Vocabulary:     map[string]bool{},
+	}
+	for _, t := range ts {
+		if t.Category == "" {
+			continue
+		}
+		c.CategoryCounts[t.Category]++
+		counts, ok := c.FeatureCounts[t.Category]
+		if !ok {
+			counts = map[string]int{}
+			c.FeatureCounts[t.Category] = counts
+		}
+		for _, f := range transactionFeatures(t) {
+			counts[f]++
+			c.FeatureTotals[t.Category]++
+			c.Vocabulary[f] = true
+		}
+	}
+	return c
+}
+
+// Suggest ranks the known categories for a transaction,
+// from most to least likely.
+//
+// At most maxResults suggestions are returned. If there is
+// no training data, the result is empty.
+func (c *Classifier) Suggest(t *Transaction, maxResults int) []*Suggestion {
+	var total int
+	for _, count := range c.CategoryCounts {
+		total += count
+	}
+	if total == 0 {
+		return []*Suggestion{}
+	}
+
+	features := transactionFeatures(t)
+	vocabSize := float64(len(c.Vocabulary) + 1)
+	logProbs := map[string]float64{}
+	maxLogProb := math.Inf(-1)
+	for category, count := range c.CategoryCounts {
+		logProb := math.Log(float64(count) / float64(total))
+		denom := float64(c.FeatureTotals[category]) + vocabSize
+		for _, f := range features {
+			// Laplace smoothing handles unseen features.
+			logProb += math.Log((float64(c.FeatureCounts[category][f]) + 1) / denom)
+		}
+		logProbs[category] = logProb
+		maxLogProb = math.Max(maxLogProb, logProb)
+	}
+
+	var normalizer float64
+	res := make([]*Suggestion, 0, len(logProbs))
+	for category, logProb := range logProbs {
+		prob := math.Exp(logProb - maxLogProb)
+		normalizer += prob
+		res = append(res, &Suggestion{Category: category, Probability: prob})
+	}
+	for _, s := range res {
+		s.Probability /= normalizer
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Probability == res[j].Probability {
+			return res[i].Category < res[j].Category
+		}
+		return res[i].Probability > res[j].Probability
+	})
+	if len(res) > maxResults {
+		res = res[:maxResults]
+	}
+	return res
+}
+
+// SuggestedFilter creates a CategoryFilter which assigns
+// the category to t and to other transactions with similar
+// descriptions.
+//
+// Runs of digits in the description, which are often
+// dates or reference numbers, are matched loosely.
+//
+// Since category filters run before replace filters, t
+// should be the transaction as the category filters see
+// it, such as the result of GlobalFilterInput for a global
+// filter.
+func SuggestedFilter(t *Transaction, category string) *CategoryFilter {
+	digits := regexp.MustCompile("[0-9]+")
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	lastEnd := 0
+	for _, match := range digits.FindAllStringIndex(t.Description, -1) {
+		pattern.WriteString(regexp.QuoteMeta(t.Description[lastEnd:match[0]]))
+		pattern.WriteString("[0-9]+")
+		lastEnd = match[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(t.Description[lastEnd:]))
+	pattern.WriteString("$")
+	return &CategoryFilter{Pattern: pattern.String(), Category: category}
+}
+
+func transactionFeatures(t *Transaction) []string {
+	var res []string
+	words := strings.FieldsFunc(strings.ToLower(t.Description), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for _, w := range words {
+		if len(w) > 1 {
+			res = append(res, "word:"+w)
+		}
+	}
+	return append(res, amountFeature(t.Amount))
+}
+
+// amountFeature buckets an amount by its sign and its
+// order of magnitude, in steps of half a decade.
+func amountFeature(amount int) string {
+	sign := "+"
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	bucket := int(math.Floor(2 * math.Log10(float64(amount)+1)))
+	return "amount:" + sign + strconv.Itoa(bucket)
+}
@@ -0,0 +1,161 @@
+package pecunia
+
+import (
+	"regexp"
+
+	"github.com/unixpickle/essentials"
+)
+
+// A filterStage maps a single transaction to a new
+// transaction, or returns nil to drop the transaction.
+type filterStage func(t *Transaction) *Transaction
+
+// A CompiledFilter is a pre-compiled version of a
+// MultiFilter.
+//
+// Unlike MultiFilter, a CompiledFilter operates directly
+// on slices, so no goroutines or channels are needed to
+// apply it.
+// It is safe to use a CompiledFilter from multiple
+// Goroutines at once.
+type CompiledFilter struct {
+	stages []filterStage
+
+	// usesFields is set if any stage matches an importer
+	// field, so that Transaction.Fields must be filled in.
+	usesFields bool
+}
+
+// Compile compiles all of the regular expressions in the
+// filter, returning an error if any are invalid.
+func (m *MultiFilter) Compile() (*CompiledFilter, error) {
+	res := &CompiledFilter{}
+	for _, p := range m.PatternFilters {
+		expr, err := regexp.CompilePOSIX(p.Pattern)
+		if err != nil {
+			return nil, essentials.AddCtx("parse pattern filter", err)
+		}
+		field := p.Field
+		res.usesFields = res.usesFields || isImporterField(field)
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if expr.MatchString(t.FieldValue(field)) {
+				return nil
+			}
+			return t
+		})
+	}
+	for _, c := range m.CategoryFilters {
+		expr, err := regexp.CompilePOSIX(c.Pattern)
+		if err != nil {
+			return nil, essentials.AddCtx("parse category filter", err)
+		}
+		category, field := c.Category, c.Field
+		res.usesFields = res.usesFields || isImporterField(field)
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if !expr.MatchString(t.FieldValue(field)) {
+				return t
+			}
+			t1 := *t
+			t1.Category = category
+			return &t1
+		})
+	}
+	for _, s := range m.SplitFilters {
+		expr, err := regexp.CompilePOSIX(s.Pattern)
+		if err != nil {
+			return nil, essentials.AddCtx("parse split filter", err)
+		}
+		if err := s.check(); err != nil {
+			return nil, err
+		}
+		split := &SplitFilter{
+			Categories: append([]string{}, s.Categories...),
+			Weights:    append([]int{}, s.Weights...),
+		}
+		field := s.Field
+		res.usesFields = res.usesFields || isImporterField(field)
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if !expr.MatchString(t.FieldValue(field)) {
+				return t
+			}
+			return split.split(t)
+		})
+	}
+	for _, r := range m.ReplaceFilters {
+		expr, err := regexp.CompilePOSIX(r.Pattern)
+		if err != nil {
+			return nil, essentials.AddCtx("parse replace filter", err)
+		}
+		replacement := r.Replacement
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if !expr.MatchString(t.Description) {
+				return t
+			}
+			t1 := *t
+			t1.Description = expr.ReplaceAllString(t.Description, replacement)
+			return &t1
+		})
+	}
+	if m.SignFilter != nil {
+		positive := m.SignFilter.Positive
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if positive != (t.Amount >= 0) {
+				return nil
+			}
+			return t
+		})
+	}
+	if m.IDFilter != nil {
+		ids := map[string]bool{}
+		for _, id := range m.IDFilter.IDs {
+			ids[id] = true
+		}
+		res.stages = append(res.stages, func(t *Transaction) *Transaction {
+			if ids[t.ID] {
+				return nil
+			}
+			return t
+		})
+	}
+	return res, nil
+}
+
+// Apply runs the filter on a slice of transactions,
+// producing a new slice.
+//
+// The input slice and its transactions are not modified.
+func (c *CompiledFilter) Apply(ts []*Transaction) []*Transaction {
+	res := make([]*Transaction, 0, len(ts))
+	for _, t := range ts {
+		if t = c.applyOne(t); t != nil {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Filter applies the filter to a channel of transactions,
+// making CompiledFilter implement Filter.
+//
+// The filter is already compiled, so this never fails.
+func (c *CompiledFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	res := make(chan *Transaction, 1)
+	go func() {
+		defer close(res)
+		for t := range ts {
+			if t = c.applyOne(t); t != nil {
+				res <- t
+			}
+		}
+	}()
+	return res, nil
+}
+
+func (c *CompiledFilter) applyOne(t *Transaction) *Transaction {
+	for _, stage := range c.stages {
+		if t = stage(t); t == nil {
+			return nil
+		}
+	}
+	return t
+}
@@ -0,0 +1,142 @@
+package pecunia
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompiledFilterEquivalence(t *testing.T) {
+	filter := testMultiFilter()
+	compiled, err := filter.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := testFilterTransactions(1000)
+	expectedChan, err := filter.Filter(TransactionsToChan(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := TransactionsToSlice(expectedChan)
+	actual := compiled.Apply(ts)
+	if len(expected) == 0 || len(expected) == len(ts) {
+		t.Fatalf("unexpected number of filtered transactions: %d", len(expected))
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatal("compiled filter output differs from MultiFilter output")
+	}
+	actualChan, err := compiled.Filter(TransactionsToChan(ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chanActual := TransactionsToSlice(actualChan)
+	if !reflect.DeepEqual(expected, chanActual) {
+		t.Fatal("compiled filter channel output differs from MultiFilter output")
+	}
+}
+
+func TestCompiledFilterDoesNotModify(t *testing.T) {
+	filter := testMultiFilter()
+	compiled, err := filter.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := testFilterTransactions(100)
+	original := testFilterTransactions(100)
+	compiled.Apply(ts)
+	if !reflect.DeepEqual(ts, original) {
+		t.Fatal("input transactions were modified")
+	}
+}
+
+func TestMultiFilterInvalidPattern(t *testing.T) {
+	filters := []*MultiFilter{
+		{PatternFilters: []*PatternFilter{{Pattern: "("}}},
+		{CategoryFilters: []*CategoryFilter{{Pattern: "[", Category: "x"}}},
+		{ReplaceFilters: []*ReplaceFilter{{Pattern: "a)"}}},
+	}
+	for i, f := range filters {
+		if _, err := f.Filter(TransactionsToChan(nil)); err == nil {
+			t.Errorf("filter %d: expected an error", i)
+		}
+	}
+}
+
+func BenchmarkCompiledFilter(b *testing.B) {
+	compiled, err := testMultiFilter().Compile()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ts := testFilterTransactions(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compiled.Apply(ts)
+	}
+}
+
+func BenchmarkMultiFilterChannels(b *testing.B) {
+	filter := testMultiFilter()
+	ts := testFilterTransactions(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := filter.Filter(TransactionsToChan(ts))
+		if err != nil {
+			b.Fatal(err)
+		}
+		TransactionsToSlice(res)
+	}
+}
+
+func testMultiFilter() *MultiFilter {
+	return &MultiFilter{
+		PatternFilters: []*PatternFilter{
+			{Pattern: "^ONLINE TRANSFER"},
+			{Pattern: "^9", Field: "Check"},
+		},
+		CategoryFilters: []*CategoryFilter{
+			{Pattern: "SAFEWAY|TRADER JOE", Category: "Groceries"},
+			{Pattern: "SHELL|CHEVRON", Category: "Gas"},
+			{Pattern: "^1", Field: "Check", Category: "Rent"},
+		},
+		SplitFilters: []*SplitFilter{
+			{Pattern: "COSTCO", Categories: []string{"Groceries", "Household"},
+				Weights: []int{2, 1}},
+		},
+		ReplaceFilters: []*ReplaceFilter{
+			{Pattern: "^PURCHASE AUTHORIZED ON [0-9/]+ ", Replacement: ""},
+			{Pattern: " CARD [0-9]+$", Replacement: ""},
+		},
+		SignFilter: &SignFilter{Positive: false},
+		IDFilter:   &IDFilter{IDs: []string{"id-3", "id-17", "id-400"}},
+	}
+}
+
+func testFilterTransactions(n int) []*Transaction {
+	gen := rand.New(rand.NewSource(1337))
+	merchants := []string{
+		"SAFEWAY", "TRADER JOE'S", "SHELL OIL", "CHEVRON", "COSTCO WHSE",
+		"NETFLIX.COM", "ONLINE TRANSFER TO SAVINGS", "AMAZON MKTPLACE",
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	res := make([]*Transaction, n)
+	for i := range res {
+		merchant := merchants[gen.Intn(len(merchants))]
+		description := merchant
+		if gen.Intn(2) == 0 {
+			description = "PURCHASE AUTHORIZED ON 01/02 " + description
+		}
+		if gen.Intn(2) == 0 {
+			description += fmt.Sprintf(" CARD %04d", gen.Intn(10000))
+		}
+		res[i] = &Transaction{
+			Time:        start.AddDate(0, 0, i/5),
+			Amount:      gen.Intn(20000) - 15000,
+			Description: description,
+			ID:          fmt.Sprintf("id-%d", i),
+			Fields:      map[string]string{"Check": fmt.Sprint(gen.Intn(1000))},
+		}
+	}
+	return res
+}
@@ -0,0 +1,245 @@
+package pecunia
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency of accounts and
+// transactions which do not specify one.
+const DefaultCurrency = "USD"
+
+// An ExchangeRate is the value of one unit of a currency
+// in another currency, as of a date.
+type ExchangeRate struct {
+	Date time.Time
+	From string
+	To   string
+	Rate float64
+}
+
+// ReadExchangeRates reads exchange rates from a CSV file
+// with the columns date (YYYY-MM-DD), from, to, and rate.
+//
+// A header row is skipped if present.
+func ReadExchangeRates(r io.Reader) ([]*ExchangeRate, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var res []*ExchangeRate
+	for i, record := range records {
+		if len(record) != 4 {
+			return nil, fmt.Errorf("row %d: expected exactly 4 columns", i+1)
+		}
+		if i == 0 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+		date, err := time.ParseInLocation("2006-01-02", record[0], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: expected date as YYYY-MM-DD but got %s", i+1, record[0])
+		}
+		rate, err := strconv.ParseFloat(record[3], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("row %d: invalid rate: %s", i+1, record[3])
+		}
+		res = append(res, &ExchangeRate{
+			Date: date,
+			From: strings.ToUpper(record[1]),
+			To:   strings.ToUpper(record[2]),
+			Rate: rate,
+		})
+	}
+	return res, nil
+}
+
+// A RateTable looks up exchange rates by date.
+type RateTable struct {
+	// Rates for each pair of currencies, sorted by date.
+	rates map[[2]string][]*ExchangeRate
+
+	// Every currency in the table, in the order they are
+	// tried as a third currency.
+	currencies []string
+}
+
+// NewRateTable creates a RateTable from a list of rates.
+func NewRateTable(rates []*ExchangeRate) *RateTable {
+	res := &RateTable{rates: map[[2]string][]*ExchangeRate{}}
+	for _, r := range rates {
+		key := [2]string{r.From, r.To}
+		res.rates[key] = append(res.rates[key], r)
+	}
+	seen := map[string]bool{}
+	for key, list := range res.rates {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Date.Before(list[j].Date)
+		})
+		for _, currency := range key {
+			if !seen[currency] {
+				seen[currency] = true
+				res.currencies = append(res.currencies, currency)
+			}
+		}
+	}
+	sort.Slice(res.currencies, func(i, j int) bool {
+		c1, c2 := res.currencies[i], res.currencies[j]
+		if (c1 == DefaultCurrency) != (c2 == DefaultCurrency) {
+			return c1 == DefaultCurrency
+		}
+		return c1 < c2
+	})
+	return res
+}
+
+// Rate gets the rate to convert from one currency to
+// another on a given date.
+//
+// The latest rate on or before the date is used, or the
+// earliest rate if there are none before it. Inverse rates
+// are used when a direct rate is not available, and
+// otherwise a conversion through a third currency is
+// attempted. The default currency is tried first as the
+// third currency, followed by the rest in alphabetical
+// order, so that the result is always the same.
+func (r *RateTable) Rate(from, to string, date time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if rate, ok := r.directRate(from, to, date); ok {
+		return rate, nil
+	}
+	for _, middle := range r.currencies {
+		if middle == from || middle == to {
+			continue
+		}
+		rate1, ok1 := r.directRate(from, middle, date)
+		rate2, ok2 := r.directRate(middle, to, date)
+		if ok1 && ok2 {
+			return rate1 * rate2, nil
+		}
+	}
+	return 0, fmt.Errorf("no exchange rate from %s to %s", from, to)
+}
+
+// Convert converts an amount in hundredths of a currency
+// unit to another currency.
+func (r *RateTable) Convert(amount int, from, to string, date time.Time) (int, error) {
+	rate, err := r.Rate(from, to, date)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(float64(amount) * rate)), nil
+}
+
+func (r *RateTable) directRate(from, to string, date time.Time) (float64, bool) {
+	if list, ok := r.rates[[2]string{from, to}]; ok {
+		return rateAt(list, date), true
+	}
+	if list, ok := r.rates[[2]string{to, from}]; ok {
+		return 1 / rateAt(list, date), true
+	}
+	return 0, false
+}
+
+func rateAt(list []*ExchangeRate, date time.Time) float64 {
+	idx := sort.Search(len(list), func(i int) bool {
+		return list[i].Date.After(date)
+	})
+	if idx == 0 {
+		return list[0].Rate
+	}
+	return list[idx-1].Rate
+}
+
+// CurrencyOf gets the currency of a transaction, using the
+// default currency if none is set.
+func (t *Transaction) CurrencyOf() string {
+	if t.Currency == "" {
+		return DefaultCurrency
+	}
+	return t.Currency
+}
+
+// CurrencyOf gets the currency of an account, using the
+// default currency if none is set.
+func (a *Account) CurrencyOf() string {
+	if a.Currency == "" {
+		return DefaultCurrency
+	}
+	return a.Currency
+}
+
+// CurrencyOf gets the currency of a budget's limit, using
+// the default currency if none is set.
+func (b *Budget) CurrencyOf() string {
+	if b.Currency == "" {
+		return DefaultCurrency
+	}
+	return b.Currency
+}
+
+// CurrencyOf gets the currency of a settlement, using the
+// default currency if none is set.
+func (s *Settlement) CurrencyOf() string {
+	if s.Currency == "" {
+		return DefaultCurrency
+	}
+	return s.Currency
+}
+
+// ConvertTransactions converts copies of the transactions
+// into a single currency, using the rates as of each
+// transaction's date.
+func ConvertTransactions(ts []*Transaction, rates *RateTable,
+	currency string) ([]*Transaction, error) {
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		from := t.CurrencyOf()
+		if from == currency {
+			res[i] = t
+			continue
+		}
+		rate, err := rates.Rate(from, currency, t.Time)
+		if err != nil {
+			return nil, err
+		}
+		t1 := *t
+		t1.Currency = currency
+		t1.Amount = int(math.Round(float64(t.Amount) * rate))
+		if len(t.Splits) > 0 {
+			// Keep the splits summing to the amount.
+			t1.Amount = 0
+			t1.Splits = make([]*Split, len(t.Splits))
+			for j, s := range t.Splits {
+				amount := int(math.Round(float64(s.Amount) * rate))
+				t1.Splits[j] = &Split{Category: s.Category, Amount: amount}
+				t1.Amount += amount
+			}
+		}
+		res[i] = &t1
+	}
+	return res, nil
+}
+
+// ConvertBudget converts a copy of a budget into another
+// currency, using the rate as of a date for its limit.
+func ConvertBudget(b *Budget, rates *RateTable, currency string,
+	date time.Time) (*Budget, error) {
+	b1 := *b
+	b1.Currency = currency
+	if from := b.CurrencyOf(); from != currency {
+		var err error
+		b1.Limit, err = rates.Convert(b.Limit, from, currency, date)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &b1, nil
+}
@@ -0,0 +1,54 @@
+package pecunia
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateTableMiddleCurrency(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	table := NewRateTable([]*ExchangeRate{
+		{Date: date, From: "EUR", To: "USD", Rate: 1.1},
+		{Date: date, From: "USD", To: "GBP", Rate: 0.8},
+		{Date: date, From: "EUR", To: "CHF", Rate: 0.9},
+		{Date: date, From: "CHF", To: "GBP", Rate: 1.0},
+		{Date: date, From: "EUR", To: "AUD", Rate: 1.6},
+		{Date: date, From: "AUD", To: "GBP", Rate: 0.5},
+	})
+	for i := 0; i < 100; i++ {
+		rate, err := table.Rate("EUR", "GBP", date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(rate-0.88) > 1e-9 {
+			t.Fatalf("expected conversion through USD (0.88) but got %f", rate)
+		}
+	}
+	rate, err := table.Rate("CHF", "AUD", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(rate-1.6/0.9) > 1e-9 {
+		t.Fatalf("expected conversion through EUR but got %f", rate)
+	}
+}
+
+func TestConvertBudget(t *testing.T) {
+	date := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rates := NewRateTable([]*ExchangeRate{{Date: date, From: "EUR", To: "USD", Rate: 1.1}})
+	b := &Budget{Category: "Food", Limit: 10000, Currency: "EUR"}
+	converted, err := ConvertBudget(b, rates, "USD", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.Limit != 11000 || converted.Currency != "USD" {
+		t.Errorf("unexpected converted budget: %d %s", converted.Limit, converted.Currency)
+	}
+	if b.Limit != 10000 || b.Currency != "EUR" {
+		t.Error("original budget was modified")
+	}
+	if _, err := ConvertBudget(b, rates, "JPY", date); err == nil {
+		t.Error("expected an error for a missing rate")
+	}
+}
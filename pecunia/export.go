@@ -0,0 +1,190 @@
+package pecunia
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// FormatAmount formats an amount in hundredths of a unit
+// as a decimal, such as "-12.34".
+func FormatAmount(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	abs := essentials.AbsInt(amount)
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+// WriteCSV writes transactions as a CSV file with a header
+// row and the columns Date, Account, Amount, Currency,
+// Description, Category, and Tags.
+//
+// Split transactions are written as one row per split, so
+// that totals by category are correct. The accounts are
+// used to look up account names.
+func WriteCSV(w io.Writer, ts []*Transaction, accounts []*Account) error {
+	names := map[string]string{}
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Date", "Account", "Amount", "Currency", "Description", "Category", "Tags"})
+	for _, t := range ExpandSplits(ts) {
+		cw.Write([]string{
+			t.Time.Format("2006-01-02"),
+			names[t.AccountID],
+			FormatAmount(t.Amount),
+			t.CurrencyOf(),
+			t.Description,
+			t.Category,
+			strings.Join(t.Tags, ";"),
+		})
+	}
+	cw.Flush()
+	return essentials.AddCtx("write CSV", cw.Error())
+}
+
+// WriteNDJSON writes transactions as newline-delimited JSON,
+// with one transaction object per line.
+func WriteNDJSON(w io.Writer, ts []*Transaction) error {
+	enc := json.NewEncoder(w)
+	for _, t := range ts {
+		if err := enc.Encode(t); err != nil {
+			return essentials.AddCtx("write NDJSON", err)
+		}
+	}
+	return nil
+}
+
+// An OFXStatement is the list of transactions for one
+// account in an OFX file.
+type OFXStatement struct {
+	Account      *Account
+	Transactions []*Transaction
+
+	// The time range covered by the statement.
+	Start time.Time
+	End   time.Time
+
+	// The balance of the account at the end of the
+	// statement.
+	Balance int
+}
+
+// WriteOFX writes statements as an OFX 2 file.
+//
+// Liability accounts are written as credit card statements,
+// and all other accounts as checking account statements.
+func WriteOFX(w io.Writer, statements []*OFXStatement) error {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	buf.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" ` +
+		`NEWFILEUID="NONE"?>` + "\n")
+	buf.WriteString("<OFX>\n")
+	buf.WriteString("<SIGNONMSGSRSV1><SONRS>\n")
+	buf.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	writeOFXElement(&buf, "DTSERVER", formatOFXTime(time.Now()))
+	writeOFXElement(&buf, "LANGUAGE", "ENG")
+	buf.WriteString("</SONRS></SIGNONMSGSRSV1>\n")
+
+	var bank, credit []*OFXStatement
+	for _, s := range statements {
+		if s.Account.IsLiability() {
+			credit = append(credit, s)
+		} else {
+			bank = append(bank, s)
+		}
+	}
+	if len(bank) > 0 {
+		buf.WriteString("<BANKMSGSRSV1>\n")
+		for _, s := range bank {
+			writeOFXStatement(&buf, s, "STMTTRNRS", "STMTRS", "BANKACCTFROM")
+		}
+		buf.WriteString("</BANKMSGSRSV1>\n")
+	}
+	if len(credit) > 0 {
+		buf.WriteString("<CREDITCARDMSGSRSV1>\n")
+		for _, s := range credit {
+			writeOFXStatement(&buf, s, "CCSTMTTRNRS", "CCSTMTRS", "CCACCTFROM")
+		}
+		buf.WriteString("</CREDITCARDMSGSRSV1>\n")
+	}
+	buf.WriteString("</OFX>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return essentials.AddCtx("write OFX", err)
+}
+
+func writeOFXStatement(buf *bytes.Buffer, s *OFXStatement, trnrs, stmtrs, acctFrom string) {
+	buf.WriteString("<" + trnrs + ">\n")
+	writeOFXElement(buf, "TRNUID", "0")
+	buf.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	buf.WriteString("<" + stmtrs + ">\n")
+	writeOFXElement(buf, "CURDEF", s.Account.CurrencyOf())
+	buf.WriteString("<" + acctFrom + ">")
+	if acctFrom == "BANKACCTFROM" {
+		writeOFXElement(buf, "BANKID", "000000000")
+	}
+	writeOFXElement(buf, "ACCTID", s.Account.ID)
+	if acctFrom == "BANKACCTFROM" {
+		writeOFXElement(buf, "ACCTTYPE", "CHECKING")
+	}
+	buf.WriteString("</" + acctFrom + ">\n")
+
+	buf.WriteString("<BANKTRANLIST>\n")
+	writeOFXElement(buf, "DTSTART", formatOFXTime(s.Start))
+	writeOFXElement(buf, "DTEND", formatOFXTime(s.End))
+	buf.WriteString("\n")
+	for _, t := range s.Transactions {
+		trnType := "CREDIT"
+		if t.Amount < 0 {
+			trnType = "DEBIT"
+		}
+		buf.WriteString("<STMTTRN>")
+		writeOFXElement(buf, "TRNTYPE", trnType)
+		writeOFXElement(buf, "DTPOSTED", formatOFXTime(t.Time))
+		writeOFXElement(buf, "TRNAMT", FormatAmount(t.Amount))
+		writeOFXElement(buf, "FITID", t.ID)
+		name := t.Description
+		if t.Merchant != "" {
+			name = t.Merchant
+		}
+		// NAME is limited to 32 characters by the spec.
+		if runes := []rune(name); len(runes) > 32 {
+			name = string(runes[:32])
+		}
+		writeOFXElement(buf, "NAME", name)
+		writeOFXElement(buf, "MEMO", t.Description)
+		buf.WriteString("</STMTTRN>\n")
+	}
+	buf.WriteString("</BANKTRANLIST>\n")
+
+	buf.WriteString("<LEDGERBAL>")
+	writeOFXElement(buf, "BALAMT", FormatAmount(s.Balance))
+	writeOFXElement(buf, "DTASOF", formatOFXTime(s.End))
+	buf.WriteString("</LEDGERBAL>\n")
+	buf.WriteString("</" + stmtrs + ">\n")
+	buf.WriteString("</" + trnrs + ">\n")
+}
+
+func writeOFXElement(buf *bytes.Buffer, name, value string) {
+	buf.WriteString("<" + name + ">")
+	xml.EscapeText(buf, []byte(value))
+	buf.WriteString("</" + name + ">")
+}
+
+func formatOFXTime(t time.Time) string {
+	_, offset := t.Zone()
+	zone := strconv.FormatFloat(float64(offset)/3600, 'f', -1, 64)
+	return t.Format("20060102150405") + "[" + zone + ":" + t.Format("MST") + "]"
+}
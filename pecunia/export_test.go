@@ -0,0 +1,28 @@
+package pecunia
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteOFXTruncatesNameByRunes(t *testing.T) {
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	name := strings.Repeat("é", 40)
+	statement := &OFXStatement{
+		Account: &Account{ID: "a", Name: "Checking"},
+		Transactions: []*Transaction{
+			{ID: "1", Time: date, Amount: -500, Description: name, Merchant: name},
+		},
+		Start: date,
+		End:   date,
+	}
+	var buf bytes.Buffer
+	if err := WriteOFX(&buf, []*OFXStatement{statement}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "<NAME>" + strings.Repeat("é", 32) + "</NAME>"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("missing truncated name in output:\n%s", buf.String())
+	}
+}
@@ -1,14 +1,20 @@
 package pecunia
 
 import (
+	"errors"
 	"regexp"
+
+	"github.com/unixpickle/essentials"
 )
 
 // A Filter is an automated mapping which is applied to
 // transactions in an account.
 type Filter interface {
 	// Filter maps transactions ts to new transactions.
-	Filter(ts <-chan *Transaction) <-chan *Transaction
+	//
+	// An error is returned if the filter is invalid, for
+	// example if it has a bad regular expression.
+	Filter(ts <-chan *Transaction) (<-chan *Transaction, error)
 }
 
 // TransactionsToChan converts a slice of transactions to
@@ -39,28 +45,44 @@ func TransactionsToSlice(ts <-chan *Transaction) []*Transaction {
 type MultiFilter struct {
 	PatternFilters  []*PatternFilter
 	CategoryFilters []*CategoryFilter
+	SplitFilters    []*SplitFilter
 	ReplaceFilters  []*ReplaceFilter
 	SignFilter      *SignFilter
 	IDFilter        *IDFilter
 }
 
-func (m *MultiFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
+func (m *MultiFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	// Check every filter before starting any Goroutines, so
+	// that none are leaked if a later filter is invalid.
+	if _, err := m.Compile(); err != nil {
+		return nil, err
+	}
+	var filters []Filter
 	for _, p := range m.PatternFilters {
-		ts = p.Filter(ts)
+		filters = append(filters, p)
 	}
 	for _, c := range m.CategoryFilters {
-		ts = c.Filter(ts)
+		filters = append(filters, c)
+	}
+	for _, s := range m.SplitFilters {
+		filters = append(filters, s)
 	}
 	for _, r := range m.ReplaceFilters {
-		ts = r.Filter(ts)
+		filters = append(filters, r)
 	}
 	if m.SignFilter != nil {
-		ts = m.SignFilter.Filter(ts)
+		filters = append(filters, m.SignFilter)
 	}
 	if m.IDFilter != nil {
-		ts = m.IDFilter.Filter(ts)
+		filters = append(filters, m.IDFilter)
+	}
+	for _, f := range filters {
+		var err error
+		if ts, err = f.Filter(ts); err != nil {
+			return nil, err
+		}
 	}
-	return ts
+	return ts, nil
 }
 
 // ReplaceFilter uses a regular expression to modify the
@@ -70,8 +92,11 @@ type ReplaceFilter struct {
 	Replacement string
 }
 
-func (r *ReplaceFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
-	patternExpr := regexp.MustCompilePOSIX(r.Pattern)
+func (r *ReplaceFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	patternExpr, err := regexp.CompilePOSIX(r.Pattern)
+	if err != nil {
+		return nil, essentials.AddCtx("parse replace filter", err)
+	}
 	res := make(chan *Transaction, 1)
 	go func() {
 		defer close(res)
@@ -86,23 +111,30 @@ func (r *ReplaceFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
 			res <- t1
 		}
 	}()
-	return res
+	return res, nil
 }
 
 // CategoryFilter sets a category for every transaction
 // whose description matches a regular expression.
+//
+// If Field is set, the named importer field is matched
+// instead of the description.
 type CategoryFilter struct {
 	Pattern  string
 	Category string
+	Field    string
 }
 
-func (c *CategoryFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
-	patternExpr := regexp.MustCompilePOSIX(c.Pattern)
+func (c *CategoryFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	patternExpr, err := regexp.CompilePOSIX(c.Pattern)
+	if err != nil {
+		return nil, essentials.AddCtx("parse category filter", err)
+	}
 	res := make(chan *Transaction, 1)
 	go func() {
 		defer close(res)
 		for t := range ts {
-			if patternExpr.MatchString(t.Description) {
+			if patternExpr.MatchString(t.FieldValue(c.Field)) {
 				t1 := *t
 				t1.Category = c.Category
 				res <- &t1
@@ -111,27 +143,90 @@ func (c *CategoryFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
 			}
 		}
 	}()
-	return res
+	return res, nil
+}
+
+// SplitFilter splits every transaction whose description
+// matches a regular expression between several categories,
+// in proportion to a weight for each category.
+//
+// If Field is set, the named importer field is matched
+// instead of the description.
+type SplitFilter struct {
+	Pattern    string
+	Field      string
+	Categories []string
+	Weights    []int
+}
+
+func (s *SplitFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	patternExpr, err := regexp.CompilePOSIX(s.Pattern)
+	if err != nil {
+		return nil, essentials.AddCtx("parse split filter", err)
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	res := make(chan *Transaction, 1)
+	go func() {
+		defer close(res)
+		for t := range ts {
+			if patternExpr.MatchString(t.FieldValue(s.Field)) {
+				res <- s.split(t)
+			} else {
+				res <- t
+			}
+		}
+	}()
+	return res, nil
+}
+
+func (s *SplitFilter) check() error {
+	if len(s.Categories) != len(s.Weights) {
+		return errors.New("split filter needs one weight per category")
+	}
+	_, err := SplitAmount(0, s.Weights)
+	return err
+}
+
+func (s *SplitFilter) split(t *Transaction) *Transaction {
+	amounts, err := SplitAmount(t.Amount, s.Weights)
+	if err != nil || len(s.Categories) != len(amounts) {
+		return t
+	}
+	t1 := *t
+	t1.Splits = make([]*Split, len(amounts))
+	for i, amount := range amounts {
+		t1.Splits[i] = &Split{Category: s.Categories[i], Amount: amount}
+	}
+	return &t1
 }
 
 // PatternFilter excludes every entry that matches a
 // regular expression.
+//
+// If Field is set, the named importer field is matched
+// instead of the description.
 type PatternFilter struct {
 	Pattern string
+	Field   string
 }
 
-func (p *PatternFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
-	patternExpr := regexp.MustCompilePOSIX(p.Pattern)
+func (p *PatternFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
+	patternExpr, err := regexp.CompilePOSIX(p.Pattern)
+	if err != nil {
+		return nil, essentials.AddCtx("parse pattern filter", err)
+	}
 	res := make(chan *Transaction, 1)
 	go func() {
 		defer close(res)
 		for t := range ts {
-			if !patternExpr.MatchString(t.Description) {
+			if !patternExpr.MatchString(t.FieldValue(p.Field)) {
 				res <- t
 			}
 		}
 	}()
-	return res
+	return res, nil
 }
 
 // SignFilter filters for either only positive or only
@@ -140,7 +235,7 @@ type SignFilter struct {
 	Positive bool
 }
 
-func (s *SignFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
+func (s *SignFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
 	res := make(chan *Transaction, 1)
 	go func() {
 		defer close(res)
@@ -150,7 +245,7 @@ func (s *SignFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
 			}
 		}
 	}()
-	return res
+	return res, nil
 }
 
 // IDFilter filters out a set of IDs from the entries.
@@ -158,7 +253,7 @@ type IDFilter struct {
 	IDs []string
 }
 
-func (i *IDFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
+func (i *IDFilter) Filter(ts <-chan *Transaction) (<-chan *Transaction, error) {
 	ids := map[string]bool{}
 	for _, id := range i.IDs {
 		ids[id] = true
@@ -172,5 +267,5 @@ func (i *IDFilter) Filter(ts <-chan *Transaction) <-chan *Transaction {
 			}
 		}
 	}()
-	return res
+	return res, nil
 }
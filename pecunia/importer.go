@@ -3,6 +3,7 @@ package pecunia
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -33,16 +34,67 @@ type TransactionImporter interface {
 	Merge(r io.Reader, existing []*Transaction) ([]*Transaction, error)
 }
 
+// A FieldImporter is a TransactionImporter that can
+// decode the raw data it stores in Transaction.Extra into
+// named fields, which filters may then match against.
+type FieldImporter interface {
+	TransactionImporter
+
+	// FieldNames lists the names of the fields which may be
+	// returned by Fields.
+	FieldNames() []string
+
+	// Fields decodes the Extra data of a transaction which
+	// was created by this importer.
+	Fields(t *Transaction) (map[string]string, error)
+}
+
+// AddFields returns copies of the transactions with the
+// Fields attribute populated, if imp is a FieldImporter.
+// Otherwise, the transactions are returned as-is.
+func AddFields(imp TransactionImporter, ts []*Transaction) ([]*Transaction, error) {
+	fi, ok := imp.(FieldImporter)
+	if !ok {
+		return ts, nil
+	}
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		fields, err := fi.Fields(t)
+		if err != nil {
+			return nil, err
+		}
+		t1 := *t
+		t1.Fields = fields
+		res[i] = &t1
+	}
+	return res, nil
+}
+
 // Importers lists the supported importers.
 func Importers() []TransactionImporter {
 	return []TransactionImporter{
 		WellsFargoImporter{},
+
+		// The journal importer is listed without an account,
+		// so its ID must be completed with the name of the
+		// journal account to import.
+		JournalImporter{},
 	}
 }
 
 // ImporterForID searches the supported importers for a
 // given ID, on returns an error if one is not found.
+//
+// IDs of the form "journal:<account>" give a
+// JournalImporter for the named journal account.
 func ImporterForID(id string) (TransactionImporter, error) {
+	if strings.HasPrefix(id, journalImporterPrefix) {
+		account := id[len(journalImporterPrefix):]
+		if account == "" {
+			return nil, errors.New("journal importer needs an account name")
+		}
+		return JournalImporter{Account: account}, nil
+	}
 	for _, imp := range Importers() {
 		if imp.ID() == id {
 			return imp, nil
@@ -105,6 +157,26 @@ func (w WellsFargoImporter) Import(r io.Reader) ([]*Transaction, error) {
 	return tns, nil
 }
 
+func (w WellsFargoImporter) FieldNames() []string {
+	return []string{"Date", "Amount", "Flag", "CheckNumber", "Description"}
+}
+
+func (w WellsFargoImporter) Fields(t *Transaction) (map[string]string, error) {
+	var record []string
+	if err := json.Unmarshal([]byte(t.Extra), &record); err != nil {
+		return nil, err
+	}
+	names := w.FieldNames()
+	if len(record) != len(names) {
+		return nil, fmt.Errorf("expected exactly %d columns", len(names))
+	}
+	res := map[string]string{}
+	for i, name := range names {
+		res[name] = record[i]
+	}
+	return res, nil
+}
+
 func (w WellsFargoImporter) Merge(r io.Reader, existing []*Transaction) ([]*Transaction, error) {
 	records, err := w.Import(r)
 	if err != nil {
@@ -0,0 +1,369 @@
+package pecunia
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Top-level account names for plain-text accounting
+// journals.
+const (
+	JournalAssets      = "Assets"
+	JournalLiabilities = "Liabilities"
+	JournalExpenses    = "Expenses"
+	JournalIncome      = "Income"
+	JournalEquity      = "Equity"
+)
+
+const (
+	journalUncategorized = "Uncategorized"
+	journalOpening       = JournalEquity + ":Opening-Balances"
+	journalTransfers     = JournalEquity + ":Transfers"
+)
+
+// WriteLedger writes accounts and their transactions as a
+// ledger or hledger journal.
+//
+// See WriteBeancount for how transactions are converted
+// into entries.
+func WriteLedger(w io.Writer, accounts []*Account, ts, history []*Transaction) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range journalEntries(accounts, ts, history) {
+		fmt.Fprintf(bw, "%s * %s\n", e.Time.Format("2006-01-02"), e.Description)
+		if e.ID != "" {
+			fmt.Fprintf(bw, "    ; id: %s\n", e.ID)
+		}
+		if len(e.Tags) > 0 {
+			var tags []string
+			for _, tag := range e.Tags {
+				tags = append(tags, journalTag(tag))
+			}
+			fmt.Fprintf(bw, "    ; :%s:\n", strings.Join(tags, ":"))
+		}
+		for _, p := range e.Postings {
+			fmt.Fprintf(bw, "    %-40s  %s\n", p.Account, p.Amount())
+		}
+		bw.WriteString("\n")
+	}
+	return essentials.AddCtx("write ledger journal", bw.Flush())
+}
+
+// WriteBeancount writes accounts and their transactions as
+// a beancount file.
+//
+// Each pecunia account becomes an asset or liability
+// account, and each category becomes an expense or income
+// account depending on whether most of its transactions in
+// history are negative or positive. The history should
+// usually be every transaction, so that the account for a
+// category does not depend on which transactions are
+// exported. A category can still move between expenses and
+// income if imports change which sign is more common, such
+// as a category of mostly refunds. Split transactions get
+// one posting per split.
+// The two sides of a linked transfer are combined into one
+// entry with two postings.
+//
+// The output only depends on the arguments, and entries
+// are sorted by date and ID, so that re-exports of the
+// same data are identical.
+func WriteBeancount(w io.Writer, accounts []*Account, ts, history []*Transaction) error {
+	bw := bufio.NewWriter(w)
+	entries := journalEntries(accounts, ts, history)
+	if len(entries) > 0 {
+		opened := map[string]bool{}
+		var names []string
+		for _, e := range entries {
+			for _, p := range e.Postings {
+				if !opened[p.Account] {
+					opened[p.Account] = true
+					names = append(names, p.Account)
+				}
+			}
+		}
+		sort.Strings(names)
+		date := entries[0].Time.Format("2006-01-02")
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s open %s\n", date, name)
+		}
+		bw.WriteString("\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(bw, "%s * ", e.Time.Format("2006-01-02"))
+		if e.Payee != "" {
+			fmt.Fprintf(bw, "%s ", beancountString(e.Payee))
+		}
+		bw.WriteString(beancountString(e.Description))
+		for _, tag := range e.Tags {
+			bw.WriteString(" #" + journalTag(tag))
+		}
+		bw.WriteString("\n")
+		if e.ID != "" {
+			fmt.Fprintf(bw, "  id: %s\n", beancountString(e.ID))
+		}
+		for _, p := range e.Postings {
+			fmt.Fprintf(bw, "  %-40s  %s\n", p.Account, p.Amount())
+		}
+		bw.WriteString("\n")
+	}
+	return essentials.AddCtx("write beancount journal", bw.Flush())
+}
+
+// JournalAccountName gets the name of the asset or
+// liability account used for a pecunia account in
+// plain-text accounting journals.
+func JournalAccountName(a *Account) string {
+	prefix := JournalAssets
+	if a.IsLiability() {
+		prefix = JournalLiabilities
+	}
+	return prefix + ":" + journalComponent(a.Name)
+}
+
+type journalPosting struct {
+	Account  string
+	Value    int
+	Currency string
+
+	// If set, the posting is converted to another currency
+	// at this total price.
+	Price         int
+	PriceCurrency string
+}
+
+func (j *journalPosting) Amount() string {
+	res := FormatAmount(j.Value) + " " + j.Currency
+	if j.PriceCurrency != "" {
+		res += " @@ " + FormatAmount(j.Price) + " " + j.PriceCurrency
+	}
+	return res
+}
+
+type journalEntry struct {
+	Time        time.Time
+	Payee       string
+	Description string
+	ID          string
+	Tags        []string
+	Postings    []*journalPosting
+}
+
+func journalEntries(accounts []*Account, ts, history []*Transaction) []*journalEntry {
+	accountNames := map[string]string{}
+	for _, a := range accounts {
+		accountNames[a.ID] = JournalAccountName(a)
+	}
+
+	incomeCategories := journalIncomeCategories(ts, history)
+	categoryAccount := func(category string, amount int) string {
+		if category == "" {
+			if amount > 0 {
+				return JournalIncome + ":" + journalUncategorized
+			}
+			return JournalExpenses + ":" + journalUncategorized
+		}
+		prefix := JournalExpenses
+		if incomeCategories[category] {
+			prefix = JournalIncome
+		}
+		var parts []string
+		for _, part := range strings.Split(category, ":") {
+			parts = append(parts, journalComponent(part))
+		}
+		return prefix + ":" + strings.Join(parts, ":")
+	}
+
+	var entries []*journalEntry
+	transfers := map[string][]*Transaction{}
+	for _, t := range ts {
+		if t.TransferID != "" {
+			transfers[t.TransferID] = append(transfers[t.TransferID], t)
+			continue
+		}
+		entry := &journalEntry{
+			Time:        t.Time,
+			Payee:       t.Merchant,
+			Description: t.Description,
+			ID:          t.ID,
+			Tags:        t.Tags,
+			Postings: []*journalPosting{
+				{Account: accountNames[t.AccountID], Value: t.Amount, Currency: t.CurrencyOf()},
+			},
+		}
+		for _, part := range ExpandSplits([]*Transaction{t}) {
+			entry.Postings = append(entry.Postings, &journalPosting{
+				Account:  categoryAccount(part.Category, part.Amount),
+				Value:    -part.Amount,
+				Currency: part.CurrencyOf(),
+			})
+		}
+		entries = append(entries, entry)
+	}
+
+	for id, sides := range transfers {
+		sort.Slice(sides, func(i, j int) bool {
+			if sides[i].Amount != sides[j].Amount {
+				return sides[i].Amount < sides[j].Amount
+			}
+			return sides[i].ID < sides[j].ID
+		})
+		from := sides[0]
+		entry := &journalEntry{
+			Time:        from.Time,
+			Description: from.Description,
+			ID:          id,
+			Tags:        from.Tags,
+		}
+		var total int
+		for _, t := range sides {
+			if t.Time.Before(entry.Time) {
+				entry.Time = t.Time
+			}
+			entry.Postings = append(entry.Postings, &journalPosting{
+				Account:  accountNames[t.AccountID],
+				Value:    t.Amount,
+				Currency: t.CurrencyOf(),
+			})
+			total += t.Amount
+		}
+		if len(sides) == 2 && sides[0].CurrencyOf() != sides[1].CurrencyOf() {
+			// Total prices are positive, even for postings
+			// with negative amounts.
+			entry.Postings[0].Price = sides[1].Amount
+			entry.Postings[0].PriceCurrency = sides[1].CurrencyOf()
+		} else if total != 0 {
+			// Only one side of the transfer was exported, or
+			// the two sides differ by a fee.
+			entry.Postings = append(entry.Postings, &journalPosting{
+				Account:  journalTransfers,
+				Value:    -total,
+				Currency: from.CurrencyOf(),
+			})
+		}
+		entries = append(entries, entry)
+	}
+
+	// Opening balances come before the first entry which
+	// involves each account.
+	firstTimes := map[string]time.Time{}
+	for _, e := range entries {
+		for _, p := range e.Postings {
+			if first, ok := firstTimes[p.Account]; !ok || e.Time.Before(first) {
+				firstTimes[p.Account] = e.Time
+			}
+		}
+	}
+	for _, a := range accounts {
+		first, ok := firstTimes[accountNames[a.ID]]
+		if !ok || a.OpeningBalance == 0 {
+			continue
+		}
+		year, month, day := first.Date()
+		entries = append(entries, &journalEntry{
+			Time:        time.Date(year, month, day, 0, 0, 0, 0, first.Location()),
+			Description: "Opening balance",
+			Postings: []*journalPosting{
+				{Account: accountNames[a.ID], Value: a.OpeningBalance, Currency: a.CurrencyOf()},
+				{Account: journalOpening, Value: -a.OpeningBalance, Currency: a.CurrencyOf()},
+			},
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		e1, e2 := entries[i], entries[j]
+		d1, d2 := e1.Time.Format("2006-01-02"), e2.Time.Format("2006-01-02")
+		if d1 != d2 {
+			return d1 < d2
+		}
+		if e1.ID != e2.ID {
+			return e1.ID < e2.ID
+		}
+		return e1.Postings[0].Account < e2.Postings[0].Account
+	})
+	return entries
+}
+
+// journalIncomeCategories finds the categories with more
+// positive than negative transactions in the history.
+// Categories missing from the history are classified by
+// the exported transactions instead.
+func journalIncomeCategories(ts, history []*Transaction) map[string]bool {
+	counts := journalSignCounts(history)
+	for category, count := range journalSignCounts(ts) {
+		if _, ok := counts[category]; !ok {
+			counts[category] = count
+		}
+	}
+	res := map[string]bool{}
+	for category, count := range counts {
+		res[category] = count > 0
+	}
+	return res
+}
+
+// journalSignCounts counts the positive transactions minus
+// the negative transactions in each category.
+func journalSignCounts(ts []*Transaction) map[string]int {
+	res := map[string]int{}
+	for _, t := range ExpandSplits(ts) {
+		if t.TransferID != "" || t.Category == "" {
+			continue
+		}
+		count := res[t.Category]
+		if t.Amount > 0 {
+			count++
+		} else if t.Amount < 0 {
+			count--
+		}
+		res[t.Category] = count
+	}
+	return res
+}
+
+// journalComponent converts a name into a valid component
+// of an account name, which starts with a capital letter
+// or digit and contains only letters, digits, and dashes.
+func journalComponent(name string) string {
+	var res strings.Builder
+	dash := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && res.Len() > 0 {
+				res.WriteRune('-')
+			}
+			dash = false
+			if res.Len() == 0 {
+				r = unicode.ToUpper(r)
+			}
+			res.WriteRune(r)
+		} else {
+			dash = true
+		}
+	}
+	if res.Len() == 0 {
+		return journalUncategorized
+	}
+	return res.String()
+}
+
+func beancountString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func journalTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_/.", r) {
+			return r
+		}
+		return '-'
+	}, tag)
+}
@@ -0,0 +1,442 @@
+package pecunia
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// journalImporterPrefix starts the ID of every
+// JournalImporter, and is followed by the journal account
+// to import.
+const journalImporterPrefix = "journal:"
+
+// A JournalImporter imports the postings to one account
+// from a ledger, hledger, or beancount journal.
+//
+// Each posting to Account becomes a transaction. If the
+// entry has one other posting, its account becomes the
+// category, without any leading "Expenses:" or "Income:".
+// If it has several, they become splits.
+type JournalImporter struct {
+	// The full name of the journal account, such as
+	// "Assets:Checking".
+	Account string
+}
+
+func (j JournalImporter) ID() string {
+	return journalImporterPrefix + j.Account
+}
+
+func (j JournalImporter) Name() string {
+	if j.Account == "" {
+		return "Ledger, hledger, or beancount journal"
+	}
+	return "Journal (" + j.Account + ")"
+}
+
+func (j JournalImporter) Import(r io.Reader) ([]*Transaction, error) {
+	if j.Account == "" {
+		return nil, errors.New("no journal account to import")
+	}
+	entries, err := parseJournal(r)
+	if err != nil {
+		return nil, err
+	}
+	var res []*Transaction
+	for _, e := range entries {
+		var own, others []*journalPosting
+		for _, p := range e.Postings {
+			if p.Account == j.Account {
+				own = append(own, p)
+			} else {
+				others = append(others, p)
+			}
+		}
+		for _, p := range own {
+			t := &Transaction{
+				Time:        e.Time,
+				Amount:      p.Value,
+				Currency:    p.Currency,
+				Description: e.Description,
+				Tags:        e.Tags,
+			}
+			var accounts []string
+			for _, other := range others {
+				accounts = append(accounts, other.Account)
+			}
+			if len(own) == 1 {
+				if len(others) == 1 {
+					t.Category = journalCategory(others[0].Account)
+				} else if len(others) > 1 {
+					t.Splits = journalSplits(t, others)
+				}
+			}
+			jsonData, _ := json.Marshal([]string{
+				e.Time.Format("2006-01-02"),
+				e.Payee,
+				e.Description,
+				FormatAmount(p.Value),
+				strings.Join(accounts, ","),
+			})
+			t.Extra = string(jsonData)
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (j JournalImporter) FieldNames() []string {
+	return []string{"Date", "Payee", "Description", "Amount", "Accounts"}
+}
+
+func (j JournalImporter) Fields(t *Transaction) (map[string]string, error) {
+	var record []string
+	if err := json.Unmarshal([]byte(t.Extra), &record); err != nil {
+		return nil, err
+	}
+	names := j.FieldNames()
+	if len(record) != len(names) {
+		return nil, fmt.Errorf("expected exactly %d fields", len(names))
+	}
+	res := map[string]string{}
+	for i, name := range names {
+		res[name] = record[i]
+	}
+	return res, nil
+}
+
+// Merge adds the imported transactions which are not
+// already in existing.
+//
+// Identical postings are counted, so that two identical
+// purchases on the same day are both kept.
+func (j JournalImporter) Merge(r io.Reader, existing []*Transaction) ([]*Transaction, error) {
+	records, err := j.Import(r)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, x := range existing {
+		counts[x.Extra]++
+	}
+	result := append([]*Transaction{}, existing...)
+	for _, record := range records {
+		if counts[record.Extra] > 0 {
+			counts[record.Extra]--
+		} else {
+			result = append(result, record)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.UnixNano() < result[j].Time.UnixNano()
+	})
+	return result, nil
+}
+
+// journalCategory converts a journal account into a
+// category.
+//
+// The "Uncategorized" accounts written by WriteLedger and
+// WriteBeancount become the empty category.
+func journalCategory(account string) string {
+	for _, prefix := range []string{JournalExpenses, JournalIncome} {
+		if len(account) > len(prefix) && strings.EqualFold(account[:len(prefix)+1], prefix+":") {
+			category := account[len(prefix)+1:]
+			if category == journalUncategorized {
+				return ""
+			}
+			return category
+		}
+	}
+	return account
+}
+
+// journalSplits creates splits for t from the other
+// postings of its entry, or returns nil if the postings do
+// not add up to the transaction amount.
+func journalSplits(t *Transaction, others []*journalPosting) []*Split {
+	var splits []*Split
+	for _, p := range others {
+		if p.Currency != t.Currency {
+			return nil
+		}
+		splits = append(splits, &Split{Category: journalCategory(p.Account), Amount: -p.Value})
+	}
+	if CheckSplits(t, splits) != nil {
+		return nil
+	}
+	return splits
+}
+
+var (
+	journalDateExpr   = regexp.MustCompile(`^(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})(=\S+)?(\s+|$)`)
+	journalQuoteExpr  = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+	journalTagExpr    = regexp.MustCompile(`#([^\s#]+)`)
+	journalMetaExpr   = regexp.MustCompile(`^[a-z][a-zA-Z0-9_-]*:(\s|$)`)
+	journalAmountExpr = regexp.MustCompile(`^([-+]?)([^-+\d.,]*)([-+]?)(\d[\d,]*(?:\.\d*)?|\.\d+)([^\d.,]*)$`)
+)
+
+// beancountDirectives are the beancount directives which
+// follow a date but are not transactions.
+var beancountDirectives = map[string]bool{
+	"open": true, "close": true, "commodity": true, "balance": true, "pad": true,
+	"price": true, "note": true, "document": true, "event": true, "query": true,
+	"custom": true,
+}
+
+// parseJournal reads the transactions of a ledger,
+// hledger, or beancount journal, ignoring other
+// directives.
+//
+// Postings without an amount get the amount which balances
+// the entry.
+func parseJournal(r io.Reader) ([]*journalEntry, error) {
+	var entries []*journalEntry
+	var cur *journalEntry
+	var missing *journalPosting
+
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		if missing != nil {
+			var total int
+			var currencies []string
+			for _, p := range cur.Postings {
+				if p != missing {
+					total += p.Value
+					if !essentials.Contains(currencies, p.Currency) {
+						currencies = append(currencies, p.Currency)
+					}
+				}
+			}
+			if len(currencies) != 1 {
+				return fmt.Errorf("cannot infer amount for %s on %s", missing.Account,
+					cur.Time.Format("2006-01-02"))
+			}
+			missing.Value = -total
+			missing.Currency = currencies[0]
+		}
+		entries = append(entries, cur)
+		cur, missing = nil, nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if entry, ok := parseJournalHeader(line); ok {
+				cur = entry
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		content := strings.TrimSpace(line)
+		if content[0] == ';' || content[0] == '#' || journalMetaExpr.MatchString(content) {
+			cur.Tags = append(cur.Tags, ledgerTags(content)...)
+			continue
+		}
+		posting, hasAmount, err := parseJournalPosting(content)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNum, err)
+		}
+		if posting == nil {
+			continue
+		}
+		if !hasAmount {
+			if missing != nil {
+				return nil, fmt.Errorf("line %d: more than one posting without an amount", lineNum)
+			}
+			missing = posting
+		}
+		cur.Postings = append(cur.Postings, posting)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseJournalHeader parses the first line of a journal
+// entry, or returns false if the line starts something
+// other than a transaction.
+func parseJournalHeader(line string) (*journalEntry, bool) {
+	m := journalDateExpr.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	entry := &journalEntry{
+		Time: time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.Local),
+	}
+
+	rest := strings.TrimSpace(line[len(m[0]):])
+	keyword := strings.Fields(rest + " ")
+	if len(keyword) > 0 && beancountDirectives[keyword[0]] {
+		return nil, false
+	}
+	if len(keyword) > 0 && (keyword[0] == "*" || keyword[0] == "!" || keyword[0] == "txn") {
+		rest = strings.TrimSpace(rest[len(keyword[0]):])
+	}
+
+	if strings.HasPrefix(rest, `"`) {
+		// Beancount: [payee] narration #tags
+		var strs []string
+		for _, q := range journalQuoteExpr.FindAllStringSubmatch(rest, -1) {
+			s := strings.ReplaceAll(q[1], `\"`, `"`)
+			strs = append(strs, strings.ReplaceAll(s, `\\`, `\`))
+		}
+		if len(strs) > 1 {
+			entry.Payee, entry.Description = strs[0], strs[1]
+		} else if len(strs) == 1 {
+			entry.Description = strs[0]
+		}
+		for _, tag := range journalTagExpr.FindAllStringSubmatch(journalQuoteExpr.ReplaceAllString(rest, ""), -1) {
+			entry.Tags = append(entry.Tags, tag[1])
+		}
+		return entry, true
+	}
+
+	// Ledger: [(code)] description [; comment]
+	if strings.HasPrefix(rest, "(") {
+		if idx := strings.Index(rest, ")"); idx != -1 {
+			rest = strings.TrimSpace(rest[idx+1:])
+		}
+	}
+	if idx := strings.Index(rest, ";"); idx != -1 {
+		entry.Tags = ledgerTags(rest[idx:])
+		rest = strings.TrimSpace(rest[:idx])
+	}
+	if idx := strings.Index(rest, " | "); idx != -1 {
+		// hledger: payee | note
+		entry.Payee = strings.TrimSpace(rest[:idx])
+	}
+	entry.Description = rest
+	return entry, true
+}
+
+// parseJournalPosting parses a posting line, with the
+// indentation removed.
+func parseJournalPosting(content string) (*journalPosting, bool, error) {
+	if idx := strings.Index(content, ";"); idx != -1 {
+		content = strings.TrimSpace(content[:idx])
+	}
+	if strings.HasPrefix(content, "* ") || strings.HasPrefix(content, "! ") {
+		content = strings.TrimSpace(content[2:])
+	}
+	if content == "" {
+		return nil, false, nil
+	}
+
+	// Ledger accounts may contain single spaces, so they are
+	// separated from amounts by two spaces or a tab.
+	// Beancount accounts never contain spaces.
+	var account, amount string
+	if idx := strings.IndexAny(content, "\t"); idx != -1 {
+		account, amount = content[:idx], content[idx+1:]
+	} else if idx := strings.Index(content, "  "); idx != -1 {
+		account, amount = content[:idx], content[idx+2:]
+	} else if idx := strings.Index(content, " "); idx != -1 && !strings.Contains(content[:idx], " ") &&
+		strings.ContainsAny(content[idx:], "0123456789") {
+		account, amount = content[:idx], content[idx+1:]
+	} else {
+		account = content
+	}
+	account = strings.Trim(strings.TrimSpace(account), "()[]")
+	amount = strings.TrimSpace(amount)
+	posting := &journalPosting{Account: account}
+	if amount == "" {
+		return posting, false, nil
+	}
+	value, currency, err := parseJournalAmount(amount)
+	if err != nil {
+		return nil, false, err
+	}
+	posting.Value = value
+	posting.Currency = currency
+	return posting, true, nil
+}
+
+// parseJournalAmount parses an amount such as "-12.50 USD"
+// or "$1,200", ignoring any price, cost, or balance
+// assertion after it.
+func parseJournalAmount(amount string) (int, string, error) {
+	if idx := strings.IndexAny(amount, "@{="); idx != -1 {
+		amount = amount[:idx]
+	}
+	compact := strings.Join(strings.Fields(amount), "")
+	m := journalAmountExpr.FindStringSubmatch(compact)
+	if m == nil {
+		return 0, "", fmt.Errorf("invalid amount: %s", amount)
+	}
+	commodity := m[2] + m[5]
+	if m[2] != "" && m[5] != "" {
+		return 0, "", fmt.Errorf("invalid amount: %s", amount)
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(m[4], ",", ""), 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid amount: %s", amount)
+	}
+	if (m[1] == "-") != (m[3] == "-") {
+		value = -value
+	}
+	return int(math.Round(value * 100)), journalCurrency(strings.Trim(commodity, `"`)), nil
+}
+
+func journalCurrency(commodity string) string {
+	switch commodity {
+	case "$":
+		return "USD"
+	case "€":
+		return "EUR"
+	case "£":
+		return "GBP"
+	case "¥":
+		return "JPY"
+	}
+	return commodity
+}
+
+// ledgerTags finds the tags in a ledger comment, which are
+// written like ":tag1:tag2:".
+func ledgerTags(comment string) []string {
+	comment = strings.TrimSpace(strings.TrimLeft(comment, ";#"))
+	if len(comment) < 2 || comment[0] != ':' || comment[len(comment)-1] != ':' ||
+		strings.Contains(comment, " ") {
+		return nil
+	}
+	var res []string
+	for _, tag := range strings.Split(comment[1:len(comment)-1], ":") {
+		if tag != "" {
+			res = append(res, tag)
+		}
+	}
+	return res
+}
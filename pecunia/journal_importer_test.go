@@ -0,0 +1,140 @@
+package pecunia
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalImporterImport(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Journal  string
+		Expected []*Transaction
+	}{
+		{
+			Name: "LedgerDollars",
+			Journal: "2026-01-02 Safeway\n" +
+				"    Expenses:Groceries  $12\n" +
+				"    Assets:Checking  $-12\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: -1200, Currency: "USD",
+					Description: "Safeway", Category: "Groceries"},
+			},
+		},
+		{
+			Name: "SlashDateElidedAmount",
+			Journal: "2026/01/02 * Paycheck\n" +
+				"    Assets:Checking  1,200.50 USD\n" +
+				"    Income:Salary\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: 120050, Currency: "USD",
+					Description: "Paycheck", Category: "Salary"},
+			},
+		},
+		{
+			Name: "DotDateElidedOwnPosting",
+			Journal: "2026.1.2 Coffee\n" +
+				"    Expenses:Eating Out  4.25 USD\n" +
+				"    Assets:Checking\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: -425, Currency: "USD",
+					Description: "Coffee", Category: "Eating Out"},
+			},
+		},
+		{
+			Name: "LedgerTags",
+			Journal: "2026-01-02 Hotel  ; :vacation:travel:\n" +
+				"    ; :business:\n" +
+				"    Expenses:Lodging  $300.00\n" +
+				"    Assets:Checking\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: -30000, Currency: "USD",
+					Description: "Hotel", Category: "Lodging",
+					Tags: []string{"vacation", "travel", "business"}},
+			},
+		},
+		{
+			Name: "BeancountSingleSpace",
+			Journal: "2026-01-01 open Assets:Checking\n" +
+				"\n" +
+				"2026-01-02 * \"Safeway\" \"Weekly groceries\" #food\n" +
+				"  Assets:Checking -12.50 USD\n" +
+				"  Expenses:Groceries 12.50 USD\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: -1250, Currency: "USD",
+					Description: "Weekly groceries", Category: "Groceries",
+					Tags: []string{"food"}},
+			},
+		},
+		{
+			Name: "Splits",
+			Journal: "2026-01-02 Target\n" +
+				"    Assets:Checking  -30.00 USD\n" +
+				"    Expenses:Groceries  20.00 USD\n" +
+				"    Expenses:Household  10.00 USD\n",
+			Expected: []*Transaction{
+				{Time: journalTestDate(2026, 1, 2), Amount: -3000, Currency: "USD",
+					Description: "Target", Splits: []*Split{
+						{Category: "Groceries", Amount: -2000},
+						{Category: "Household", Amount: -1000},
+					}},
+			},
+		},
+		{
+			Name: "OtherAccount",
+			Journal: "2026-01-02 Rent\n" +
+				"    Expenses:Rent  $1000\n" +
+				"    Assets:Savings\n",
+			Expected: nil,
+		},
+	}
+	importer := JournalImporter{Account: "Assets:Checking"}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			actual, err := importer.Import(strings.NewReader(c.Journal))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, x := range actual {
+				x.Extra = ""
+			}
+			if !reflect.DeepEqual(actual, c.Expected) {
+				t.Errorf("expected %s but got %s", journalTestString(c.Expected),
+					journalTestString(actual))
+			}
+		})
+	}
+}
+
+func TestJournalImporterErrors(t *testing.T) {
+	journals := []string{
+		"2026-01-02 Two elided\n    Assets:Checking\n    Expenses:Food\n",
+		"2026-01-02 Bad amount\n    Assets:Checking  12.3.4 USD\n    Expenses:Food\n",
+		"2026-01-02 Mixed\n    Assets:Checking\n    Expenses:Food  $1\n    Expenses:Wine  1 EUR\n",
+	}
+	importer := JournalImporter{Account: "Assets:Checking"}
+	for _, journal := range journals {
+		if _, err := importer.Import(strings.NewReader(journal)); err == nil {
+			t.Errorf("expected error for journal:\n%s", journal)
+		}
+	}
+}
+
+func journalTestDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 12, 0, 0, 0, time.Local)
+}
+
+func journalTestString(ts []*Transaction) string {
+	var lines []string
+	for _, t := range ts {
+		line := t.Time.Format("2006-01-02") + " " + FormatAmount(t.Amount) + " " + t.Currency +
+			" " + t.Description + " [" + t.Category + "] " + strings.Join(t.Tags, ",")
+		for _, s := range t.Splits {
+			line += " (" + s.Category + " " + FormatAmount(s.Amount) + ")"
+		}
+		lines = append(lines, line)
+	}
+	return "[" + strings.Join(lines, "; ") + "]"
+}
@@ -0,0 +1,67 @@
+package pecunia
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteLedgerCrossCurrencyTransfer(t *testing.T) {
+	accounts := []*Account{
+		{ID: "a", Name: "Checking", Currency: "USD"},
+		{ID: "b", Name: "Euro Savings", Currency: "EUR"},
+	}
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ts := []*Transaction{
+		{ID: "1", AccountID: "a", TransferID: "x", Time: date, Amount: -10000,
+			Currency: "USD", Description: "TRANSFER TO SAVINGS"},
+		{ID: "2", AccountID: "b", TransferID: "x", Time: date, Amount: 9000,
+			Currency: "EUR", Description: "TRANSFER FROM CHECKING"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLedger(&buf, accounts, ts, ts); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	if !strings.Contains(output, "-100.00 USD @@ 90.00 EUR") {
+		t.Errorf("missing positive total price in ledger output:\n%s", output)
+	}
+
+	buf.Reset()
+	if err := WriteBeancount(&buf, accounts, ts, ts); err != nil {
+		t.Fatal(err)
+	}
+	output = buf.String()
+	if !strings.Contains(output, "-100.00 USD @@ 90.00 EUR") {
+		t.Errorf("missing positive total price in beancount output:\n%s", output)
+	}
+	if strings.Contains(output, journalTransfers) {
+		t.Errorf("unexpected residual posting in beancount output:\n%s", output)
+	}
+}
+
+func TestWriteLedgerStableCategories(t *testing.T) {
+	accounts := []*Account{{ID: "a", Name: "Checking"}}
+	// The earliest transaction is a refund, but most of the
+	// category is spending.
+	history := []*Transaction{
+		{ID: "1", AccountID: "a", Time: time.Date(2025, 12, 5, 0, 0, 0, 0, time.UTC),
+			Amount: 2000, Category: "Shopping", Description: "STORE REFUND"},
+		{ID: "2", AccountID: "a", Time: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC),
+			Amount: -5000, Category: "Shopping", Description: "STORE"},
+		{ID: "3", AccountID: "a", Time: time.Date(2026, 2, 5, 0, 0, 0, 0, time.UTC),
+			Amount: -8000, Category: "Shopping", Description: "STORE"},
+	}
+	for _, ts := range [][]*Transaction{history, history[:1], history[1:]} {
+		var buf bytes.Buffer
+		if err := WriteLedger(&buf, accounts, ts, history); err != nil {
+			t.Fatal(err)
+		}
+		output := buf.String()
+		if !strings.Contains(output, "Expenses:Shopping") || strings.Contains(output, "Income:") {
+			t.Errorf("unexpected category account in ledger output:\n%s", output)
+		}
+	}
+}
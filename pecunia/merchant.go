@@ -0,0 +1,197 @@
+package pecunia
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/unixpickle/essentials"
+)
+
+// MerchantField is the field name which filters can use
+// to match a transaction's Merchant.
+const MerchantField = "merchant"
+
+// A MerchantAlias assigns a merchant name to every
+// transaction whose description matches a regular
+// expression.
+type MerchantAlias struct {
+	Pattern  string
+	Merchant string
+}
+
+// A MerchantNormalizer derives merchant names from
+// transaction descriptions.
+type MerchantNormalizer struct {
+	aliases []*regexp.Regexp
+	names   []string
+}
+
+// NewMerchantNormalizer creates a MerchantNormalizer which
+// uses the given aliases before falling back on built-in
+// cleanup rules.
+func NewMerchantNormalizer(aliases []*MerchantAlias) (*MerchantNormalizer, error) {
+	res := &MerchantNormalizer{}
+	for _, a := range aliases {
+		expr, err := regexp.CompilePOSIX(a.Pattern)
+		if err != nil {
+			return nil, essentials.AddCtx("parse merchant alias", err)
+		}
+		res.aliases = append(res.aliases, expr)
+		res.names = append(res.names, a.Merchant)
+	}
+	return res, nil
+}
+
+// Merchant gets the merchant name for a description.
+//
+// The first matching alias is used if there is one.
+// Otherwise, payment processor prefixes, dates, store and
+// card numbers, and trailing locations are stripped.
+func (m *MerchantNormalizer) Merchant(desc string) string {
+	for i, expr := range m.aliases {
+		if expr.MatchString(desc) {
+			return m.names[i]
+		}
+	}
+	return CleanMerchant(desc)
+}
+
+// AddMerchants returns copies of the transactions with
+// their Merchant fields set.
+func (m *MerchantNormalizer) AddMerchants(ts []*Transaction) []*Transaction {
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		t1 := *t
+		t1.Merchant = m.Merchant(t.Description)
+		res[i] = &t1
+	}
+	return res
+}
+
+var (
+	merchantPrefixExpr = regexp.MustCompile(`^((PURCHASE|RECURRING PAYMENT|PAYMENT) ` +
+		`AUTHORIZED ON [0-9]+/[0-9]+|CHECKCARD [0-9]+|POS (PURCHASE )?|DEBIT CARD PURCHASE|` +
+		`SQ ?\*|TST ?\*|SP ?\*|PAYPAL ?\*|PP ?\*|IC ?\*|GOOGLE ?\*|APL ?\*|AMZN MKTP US ?\*)\s*`)
+	merchantCardExpr = regexp.MustCompile(`\b(CARD|XX+)\s*[0-9]{4}\b|\bREF ?#? ?[0-9A-Z]+$`)
+	merchantDateExpr = regexp.MustCompile(`\b[0-9]{1,2}/[0-9]{1,2}(/[0-9]{2,4})?\b`)
+	merchantSpace    = regexp.MustCompile(`\s+`)
+)
+
+var usStates = map[string]bool{}
+
+// cityPrefixes are common first words of two-word city
+// names, such as "SAN" in "SAN FRANCISCO".
+var cityPrefixes = map[string]bool{}
+
+func init() {
+	for _, s := range strings.Fields("AL AK AZ AR CA CO CT DE DC FL GA HI ID IL IN IA KS KY " +
+		"LA ME MD MA MI MN MS MO MT NE NV NH NJ NM NY NC ND OH OK OR PA RI SC SD TN TX UT " +
+		"VT VA WA WV WI WY") {
+		usStates[s] = true
+	}
+	for _, s := range strings.Fields("SAN SANTA LOS LAS NEW FORT FT ST SAINT EL LA PALO SALT " +
+		"NORTH SOUTH EAST WEST PORT MOUNT MT LONG GRAND DALY REDWOOD CULVER BEVERLY") {
+		cityPrefixes[s] = true
+	}
+}
+
+// CleanMerchant applies the built-in cleanup rules of
+// MerchantNormalizer to a description.
+func CleanMerchant(desc string) string {
+	s := strings.ToUpper(strings.TrimSpace(desc))
+	s = merchantPrefixExpr.ReplaceAllString(s, "")
+	s = merchantCardExpr.ReplaceAllString(s, " ")
+	s = merchantDateExpr.ReplaceAllString(s, " ")
+
+	words := strings.Fields(merchantSpace.ReplaceAllString(s, " "))
+	if len(words) > 0 && strings.HasPrefix(words[0], "#") {
+		words = words[1:]
+	}
+
+	// Everything after a store or reference number is a
+	// location, so it is removed to give every location of
+	// a chain the same name. A number at the start is
+	// usually part of the name, as in "7-ELEVEN" or "24
+	// HOUR FITNESS".
+	for i, w := range words {
+		if i > 0 && (strings.HasPrefix(w, "#") || strings.ContainsAny(w, "0123456789")) {
+			words = words[:i]
+			break
+		}
+	}
+
+	// Without a store number, remove a trailing "CITY ST",
+	// as long as it leaves behind a name.
+	if len(words) > 1 && usStates[words[len(words)-1]] {
+		words = words[:len(words)-1]
+		if len(words) > 1 {
+			words = words[:len(words)-1]
+			if len(words) > 1 && cityPrefixes[words[len(words)-1]] {
+				words = words[:len(words)-1]
+			}
+		}
+	}
+
+	// If nothing resembling a name is left, the original
+	// description is better than a number.
+	if !strings.ContainsAny(strings.Join(words, ""), "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		return strings.TrimSpace(desc)
+	}
+	for i, w := range words {
+		words[i] = titleWord(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// titleWord lowercases a word, except for letters at the
+// start of the word or after punctuation, as in "7-Eleven"
+// or "Netflix.Com". Apostrophes are not treated as
+// punctuation, so "JOE'S" becomes "Joe's".
+func titleWord(w string) string {
+	var res strings.Builder
+	prev := ' '
+	for _, r := range strings.ToLower(w) {
+		if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != '\'' {
+			r = unicode.ToUpper(r)
+		}
+		res.WriteRune(r)
+		prev = r
+	}
+	return res.String()
+}
+
+// A MerchantTotal summarizes the transactions for a
+// single merchant.
+type MerchantTotal struct {
+	Merchant string
+	Count    int
+	Amount   int
+}
+
+// MerchantTotals totals up transactions by merchant,
+// sorted from the largest to the smallest total spending.
+func MerchantTotals(ts []*Transaction) []*MerchantTotal {
+	totals := map[string]*MerchantTotal{}
+	for _, t := range ts {
+		total, ok := totals[t.Merchant]
+		if !ok {
+			total = &MerchantTotal{Merchant: t.Merchant}
+			totals[t.Merchant] = total
+		}
+		total.Count++
+		total.Amount += t.Amount
+	}
+	res := make([]*MerchantTotal, 0, len(totals))
+	for _, total := range totals {
+		res = append(res, total)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Amount == res[j].Amount {
+			return res[i].Merchant < res[j].Merchant
+		}
+		return res[i].Amount < res[j].Amount
+	})
+	return res
+}
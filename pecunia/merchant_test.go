@@ -0,0 +1,25 @@
+package pecunia
+
+import "testing"
+
+func TestCleanMerchant(t *testing.T) {
+	cases := map[string]string{
+		"7-ELEVEN 12345 SAN FRANCISCO CA":                                   "7-Eleven",
+		"24 HOUR FITNESS 0123 LOS ANGELES CA":                               "24 Hour Fitness",
+		"SQ *BLUE BOTTLE 1234 OAKLAND CA 01/15":                             "Blue Bottle",
+		"NETFLIX.COM 866-579-7172 CA":                                       "Netflix.Com",
+		"BEST BUY 00012 SF CA":                                              "Best Buy",
+		"PURCHASE AUTHORIZED ON 01/02 SHELL OIL 5744 SAN JOSE CA CARD 1234": "Shell Oil",
+		"12345 SAN FRANCISCO CA":                                            "12345 SAN FRANCISCO CA",
+		"SAFEWAY #1234 OAKLAND CA":                                          "Safeway",
+		"SAFEWAY #99 BERKELEY CA":                                           "Safeway",
+		"SAFEWAY OAKLAND CA":                                                "Safeway",
+		"TARGET 00012345 SAN JOSE CA":                                       "Target",
+		"TRADER JOE'S SAN FRANCISCO CA":                                     "Trader Joe's",
+	}
+	for desc, expected := range cases {
+		if actual := CleanMerchant(desc); actual != expected {
+			t.Errorf("%q: expected %q but got %q", desc, expected, actual)
+		}
+	}
+}
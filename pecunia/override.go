@@ -0,0 +1,46 @@
+package pecunia
+
+// An Override manually changes a single transaction after
+// all filters have been applied to it.
+//
+// Empty fields leave the corresponding attributes of the
+// transaction unchanged.
+// Splits are ignored if they do not sum to the amount of
+// the transaction.
+type Override struct {
+	Category    string
+	Description string
+	Tags        []string
+	Splits      []*Split
+}
+
+// ApplyOverrides applies overrides, keyed by transaction
+// ID, to a list of transactions.
+//
+// Transactions which are modified are copied, and the
+// input slice and its transactions are not modified.
+func ApplyOverrides(ts []*Transaction, overrides map[string]*Override) []*Transaction {
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		o, ok := overrides[t.ID]
+		if !ok {
+			res[i] = t
+			continue
+		}
+		t1 := *t
+		if o.Category != "" {
+			t1.Category = o.Category
+		}
+		if o.Description != "" {
+			t1.Description = o.Description
+		}
+		if len(o.Tags) > 0 {
+			t1.Tags = append([]string{}, o.Tags...)
+		}
+		if len(o.Splits) > 0 && CheckSplits(t, o.Splits) == nil {
+			t1.Splits = append([]*Split{}, o.Splits...)
+		}
+		res[i] = &t1
+	}
+	return res
+}
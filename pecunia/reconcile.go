@@ -0,0 +1,119 @@
+package pecunia
+
+import (
+	"errors"
+	"time"
+)
+
+// A Reconciliation compares an account's balance to the
+// ending balance from a bank statement.
+type Reconciliation struct {
+	// The last day covered by the statement.
+	StatementDate time.Time
+
+	Balance    int
+	Computed   int
+	Difference int
+
+	// Completed is set if the balances matched and the
+	// transactions were marked as reconciled.
+	Completed bool
+}
+
+// Reconcile compares the balance of an account at the end
+// of a statement date to a statement's ending balance.
+//
+// If the balances match, a copy of the transactions is
+// returned with every transaction up to the end of the
+// statement date marked as reconciled. Otherwise, the
+// returned transactions are nil.
+func Reconcile(a *Account, ts []*Transaction, statementDate time.Time,
+	balance int) (*Reconciliation, []*Transaction) {
+	end := statementEnd(statementDate)
+	computed := BalanceAt(a, ts, end)
+	res := &Reconciliation{
+		StatementDate: statementDate,
+		Balance:       balance,
+		Computed:      computed,
+		Difference:    balance - computed,
+	}
+	if res.Difference != 0 {
+		return res, nil
+	}
+	res.Completed = true
+	marked := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		t1 := *t
+		if t.Time.Before(end) {
+			t1.Reconciled = true
+		}
+		marked[i] = &t1
+	}
+	return res, marked
+}
+
+// ReconciledThrough gets the end of the latest completed
+// reconciliation of the account, or the zero time if the
+// account has never been reconciled.
+func (a *Account) ReconciledThrough() time.Time {
+	var res time.Time
+	for _, r := range a.Reconciliations {
+		if end := statementEnd(r.StatementDate); r.Completed && end.After(res) {
+			res = end
+		}
+	}
+	return res
+}
+
+// LockReconciled prevents an update to an account's
+// transactions, such as an import, from changing any
+// reconciled transactions.
+//
+// Reconciled transactions from old which are missing or
+// modified in updated are restored, and transactions which
+// are new in updated but fall within the reconciled period
+// are dropped, since they would change the reconciled
+// balance. The number of dropped transactions is returned.
+func LockReconciled(a *Account, old, updated []*Transaction) ([]*Transaction, int) {
+	through := a.ReconciledThrough()
+	locked := map[string]*Transaction{}
+	for _, t := range old {
+		if t.Reconciled {
+			locked[t.ID] = t
+		}
+	}
+
+	var dropped int
+	res := make([]*Transaction, 0, len(updated))
+	for _, t := range updated {
+		if lockedT, ok := locked[t.ID]; ok {
+			res = append(res, lockedT)
+			delete(locked, t.ID)
+		} else if t.ID == "" && t.Time.Before(through) {
+			dropped++
+		} else {
+			res = append(res, t)
+		}
+	}
+	for _, t := range old {
+		if _, ok := locked[t.ID]; ok {
+			res = append(res, t)
+		}
+	}
+	return sortedByTime(res), dropped
+}
+
+// CheckUnreconciled returns an error if any of the
+// transactions are reconciled.
+func CheckUnreconciled(ts []*Transaction) error {
+	for _, t := range ts {
+		if t.Reconciled {
+			return errors.New("account has reconciled transactions")
+		}
+	}
+	return nil
+}
+
+func statementEnd(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).AddDate(0, 0, 1)
+}
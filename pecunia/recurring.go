@@ -0,0 +1,232 @@
+package pecunia
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Intervals for recurring transactions.
+const (
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+	IntervalYearly  = "yearly"
+)
+
+// A Recurring is a series of similar transactions from the
+// same merchant, occurring at a regular interval, such as
+// a subscription or a paycheck.
+type Recurring struct {
+	AccountID string
+	Merchant  string
+	Interval  string
+
+	// Amounts of the series, in cents.
+	AverageAmount int
+	LastAmount    int
+
+	LastTime time.Time
+	NextTime time.Time
+
+	// PriceChanged is set if the latest amount differs from
+	// the one before it.
+	PriceChanged bool
+
+	// Missed is set if the next expected transaction is
+	// overdue.
+	Missed bool
+
+	TransactionIDs []string
+}
+
+type intervalInfo struct {
+	Name      string
+	Days      float64
+	Tolerance float64
+	MinCount  int
+}
+
+var recurringIntervals = []intervalInfo{
+	{Name: IntervalWeekly, Days: 7, Tolerance: 1, MinCount: 3},
+	{Name: IntervalMonthly, Days: 30.4, Tolerance: 4, MinCount: 3},
+	{Name: IntervalYearly, Days: 365.25, Tolerance: 15, MinCount: 2},
+}
+
+// DetectRecurring finds recurring series of transactions
+// within each account.
+//
+// Transactions are grouped by account, merchant, and sign.
+// A group is recurring if most of the gaps between its
+// transactions are close to a week, a month, or a year,
+// and if its amounts are all within half of the median.
+// The now argument is used to determine missed payments.
+func DetectRecurring(ts []*Transaction, now time.Time) []*Recurring {
+	groups := map[[3]string][]*Transaction{}
+	var keys [][3]string
+	for _, t := range ts {
+		merchant := t.Merchant
+		if merchant == "" {
+			merchant = normalizeDescription(t.Description)
+		}
+		if merchant == "" || t.Amount == 0 {
+			continue
+		}
+		sign := "+"
+		if t.Amount < 0 {
+			sign = "-"
+		}
+		key := [3]string{t.AccountID, merchant, sign}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	var res []*Recurring
+	for _, key := range keys {
+		if r := detectRecurringGroup(groups[key], now); r != nil {
+			r.AccountID = key[0]
+			r.Merchant = key[1]
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].NextTime.Before(res[j].NextTime)
+	})
+	return res
+}
+
+func detectRecurringGroup(ts []*Transaction, now time.Time) *Recurring {
+	ts = append([]*Transaction{}, ts...)
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Time.Before(ts[j].Time)
+	})
+	if len(ts) < 2 {
+		return nil
+	}
+
+	amounts := make([]int, len(ts))
+	for i, t := range ts {
+		amounts[i] = essentials.AbsInt(t.Amount)
+	}
+	median := medianInt(amounts)
+	for _, a := range amounts {
+		if essentials.AbsInt(a-median)*2 > median {
+			return nil
+		}
+	}
+
+	for _, interval := range recurringIntervals {
+		if len(ts) < interval.MinCount {
+			continue
+		}
+		var regular int
+		for i := 1; i < len(ts); i++ {
+			days := ts[i].Time.Sub(ts[i-1].Time).Hours() / 24
+			if days >= interval.Days-interval.Tolerance && days <= interval.Days+interval.Tolerance {
+				regular++
+			}
+		}
+		// Allow the occasional irregular gap, e.g. from a
+		// payment which was posted late.
+		if regular*4 < (len(ts)-1)*3 {
+			continue
+		}
+		last := ts[len(ts)-1]
+		var total int
+		res := &Recurring{
+			Interval:   interval.Name,
+			LastAmount: last.Amount,
+			LastTime:   last.Time,
+			NextTime:   nextRecurrence(last.Time, interval.Name),
+		}
+		for _, t := range ts {
+			total += t.Amount
+			res.TransactionIDs = append(res.TransactionIDs, t.ID)
+		}
+		res.AverageAmount = total / len(ts)
+		res.PriceChanged = ts[len(ts)-2].Amount != last.Amount
+		grace := time.Duration(interval.Tolerance*24) * time.Hour
+		res.Missed = now.After(res.NextTime.Add(grace))
+		return res
+	}
+	return nil
+}
+
+// UpcomingCount counts the expected transactions in the
+// series after start and before end.
+//
+// Missed series are assumed to have stopped, so they have
+// no upcoming transactions.
+func (r *Recurring) UpcomingCount(start, end time.Time) int {
+	if r.Missed {
+		return 0
+	}
+
+	// Count from the last transaction when it is known, so
+	// that a monthly series on the 31st returns to the 31st
+	// after a shorter month.
+	anchor, n := r.NextTime, 0
+	if !r.LastTime.IsZero() {
+		anchor, n = r.LastTime, 1
+	}
+	var count int
+	for t := addIntervals(anchor, r.Interval, n); t.Before(end); t = addIntervals(anchor, r.Interval, n) {
+		if t.After(start) {
+			count++
+		}
+		n++
+	}
+	return count
+}
+
+func nextRecurrence(t time.Time, interval string) time.Time {
+	return addIntervals(t, interval, 1)
+}
+
+// addIntervals adds n weeks, months, or years to t.
+//
+// Unlike time.Time.AddDate, months and years are clamped
+// to the end of the resulting month rather than overflowing
+// into the next one, so January 31st plus a month is
+// February 28th (or 29th) rather than early March.
+func addIntervals(t time.Time, interval string, n int) time.Time {
+	switch interval {
+	case IntervalWeekly:
+		return t.AddDate(0, 0, 7*n)
+	case IntervalMonthly:
+		return addMonths(t, n)
+	default:
+		return addMonths(t, 12*n)
+	}
+}
+
+// addMonths adds n months to t, clamping the day to the
+// last day of the resulting month.
+func addMonths(t time.Time, n int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// normalizeDescription reduces a description to its
+// lowercase words, dropping numbers and punctuation which
+// often vary between otherwise identical transactions.
+func normalizeDescription(desc string) string {
+	words := strings.FieldsFunc(strings.ToLower(desc), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	return strings.Join(words, " ")
+}
+
+func medianInt(xs []int) int {
+	sorted := append([]int{}, xs...)
+	sort.Ints(sorted)
+	return sorted[len(sorted)/2]
+}
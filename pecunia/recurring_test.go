@@ -0,0 +1,46 @@
+package pecunia
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurringUpcomingCount(t *testing.T) {
+	r := &Recurring{
+		Interval: IntervalWeekly,
+		NextTime: time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC),
+	}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	if count := r.UpcomingCount(start, end); count != 4 {
+		t.Errorf("expected 4 weekly charges but got %d", count)
+	}
+	if count := r.UpcomingCount(start.AddDate(0, 0, 10), end); count != 3 {
+		t.Errorf("expected 3 weekly charges but got %d", count)
+	}
+	r.Missed = true
+	if count := r.UpcomingCount(start, end); count != 0 {
+		t.Errorf("expected no charges for a missed series but got %d", count)
+	}
+}
+
+func TestRecurringMonthEnd(t *testing.T) {
+	last := time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)
+	next := nextRecurrence(last, IntervalMonthly)
+	if expected := time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("expected next charge on %v but got %v", expected, next)
+	}
+	leap := nextRecurrence(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), IntervalYearly)
+	if expected := time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC); !leap.Equal(expected) {
+		t.Errorf("expected next charge on %v but got %v", expected, leap)
+	}
+
+	r := &Recurring{Interval: IntervalMonthly, LastTime: last, NextTime: next}
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if count := r.UpcomingCount(start, time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)); count != 0 {
+		t.Errorf("expected no charges before March 31st but got %d", count)
+	}
+	if count := r.UpcomingCount(start, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)); count != 1 {
+		t.Errorf("expected a charge on March 31st but got %d", count)
+	}
+}
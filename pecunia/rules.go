@@ -0,0 +1,257 @@
+package pecunia
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseRules reads a MultiFilter from a human-editable
+// rules file.
+//
+// A rules file contains one rule per line. Blank lines
+// are ignored, and a '#' outside of a quoted string starts
+// a comment which extends to the end of the line.
+// Each rule is a keyword followed by whitespace-separated
+// arguments. Arguments containing whitespace, quotes, or
+// '#' must be double-quoted, using Go escaping rules.
+//
+// The supported rules are:
+//
+//	exclude <pattern> [in <field>]
+//	category <category> <pattern> [in <field>]
+//	split <pattern> <category>=<weight> [...] [in <field>]
+//	replace <pattern> <replacement>
+//	sign positive|negative
+//	exclude-id <id> [<id> ...]
+//
+// For example, "split RENT Rent=1 Utilities=1" splits rent
+// payments evenly between two categories.
+//
+// The optional "in <field>" suffix matches the pattern
+// against a named importer field rather than against the
+// transaction description.
+//
+// Rules of each kind are applied in the order they appear,
+// with the kinds applied in the same order as MultiFilter.
+func ParseRules(r io.Reader) (*MultiFilter, error) {
+	res := &MultiFilter{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		fields, err := splitRuleLine(scanner.Text())
+		if err == nil && len(fields) > 0 {
+			err = addRule(res, fields)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rules line %d: %s", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := res.Compile(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// WriteRules encodes a MultiFilter as a rules file which
+// can be read back with ParseRules.
+func WriteRules(w io.Writer, m *MultiFilter) error {
+	var lines []string
+	for _, p := range m.PatternFilters {
+		lines = append(lines, formatRule("exclude", withField([]string{p.Pattern}, p.Field)...))
+	}
+	for _, c := range m.CategoryFilters {
+		args := withField([]string{c.Category, c.Pattern}, c.Field)
+		lines = append(lines, formatRule("category", args...))
+	}
+	for _, s := range m.SplitFilters {
+		args := []string{s.Pattern}
+		for i, category := range s.Categories {
+			args = append(args, category+"="+strconv.Itoa(s.Weights[i]))
+		}
+		lines = append(lines, formatRule("split", withField(args, s.Field)...))
+	}
+	for _, r := range m.ReplaceFilters {
+		lines = append(lines, formatRule("replace", r.Pattern, r.Replacement))
+	}
+	if m.SignFilter != nil {
+		if m.SignFilter.Positive {
+			lines = append(lines, "sign positive")
+		} else {
+			lines = append(lines, "sign negative")
+		}
+	}
+	if m.IDFilter != nil && len(m.IDFilter.IDs) > 0 {
+		lines = append(lines, formatRule("exclude-id", m.IDFilter.IDs...))
+	}
+	for _, line := range lines {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addRule(m *MultiFilter, fields []string) error {
+	keyword, args := fields[0], fields[1:]
+	checkArgs := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s: expected %d arguments but got %d", keyword, n, len(args))
+		}
+		return nil
+	}
+	var field string
+	if keyword == "exclude" || keyword == "category" || keyword == "split" {
+		if n := len(args); n > 2 && args[n-2] == "in" {
+			field = args[n-1]
+			args = args[:n-2]
+		}
+	}
+	switch keyword {
+	case "exclude":
+		if err := checkArgs(1); err != nil {
+			return err
+		}
+		m.PatternFilters = append(m.PatternFilters, &PatternFilter{
+			Pattern: args[0],
+			Field:   field,
+		})
+	case "category":
+		if err := checkArgs(2); err != nil {
+			return err
+		}
+		m.CategoryFilters = append(m.CategoryFilters, &CategoryFilter{
+			Category: args[0],
+			Pattern:  args[1],
+			Field:    field,
+		})
+	case "split":
+		if len(args) < 2 {
+			return errors.New("split: expected a pattern and at least one category")
+		}
+		split := &SplitFilter{Pattern: args[0], Field: field}
+		for _, arg := range args[1:] {
+			idx := strings.LastIndex(arg, "=")
+			if idx == -1 {
+				return fmt.Errorf("split: expected <category>=<weight> but got %s", arg)
+			}
+			weight, err := strconv.Atoi(arg[idx+1:])
+			if err != nil {
+				return fmt.Errorf("split: invalid weight: %s", arg[idx+1:])
+			}
+			split.Categories = append(split.Categories, arg[:idx])
+			split.Weights = append(split.Weights, weight)
+		}
+		m.SplitFilters = append(m.SplitFilters, split)
+	case "replace":
+		if err := checkArgs(2); err != nil {
+			return err
+		}
+		m.ReplaceFilters = append(m.ReplaceFilters, &ReplaceFilter{
+			Pattern:     args[0],
+			Replacement: args[1],
+		})
+	case "sign":
+		if err := checkArgs(1); err != nil {
+			return err
+		}
+		if m.SignFilter != nil {
+			return errors.New("sign: only one sign rule is allowed")
+		}
+		switch args[0] {
+		case "positive":
+			m.SignFilter = &SignFilter{Positive: true}
+		case "negative":
+			m.SignFilter = &SignFilter{Positive: false}
+		default:
+			return fmt.Errorf("sign: unknown sign: %s", args[0])
+		}
+	case "exclude-id":
+		if len(args) == 0 {
+			return errors.New("exclude-id: expected at least one ID")
+		}
+		if m.IDFilter == nil {
+			m.IDFilter = &IDFilter{}
+		}
+		m.IDFilter.IDs = append(m.IDFilter.IDs, args...)
+	default:
+		return fmt.Errorf("unknown rule: %s", keyword)
+	}
+	return nil
+}
+
+func splitRuleLine(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeftFunc(line, unicode.IsSpace)
+		if line == "" || line[0] == '#' {
+			return fields, nil
+		}
+		if line[0] == '"' {
+			end := quotedEnd(line)
+			if end == -1 {
+				return nil, errors.New("unterminated quoted string")
+			}
+			field, err := strconv.Unquote(line[:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string: %s", line[:end])
+			}
+			line = line[end:]
+			if line != "" && !unicode.IsSpace(rune(line[0])) {
+				return nil, fmt.Errorf("quoted argument must be followed by a space: %s", line)
+			}
+			fields = append(fields, field)
+			continue
+		}
+		end := strings.IndexFunc(line, unicode.IsSpace)
+		if end == -1 {
+			end = len(line)
+		}
+		field := line[:end]
+		if strings.ContainsAny(field, "\"#") {
+			return nil, fmt.Errorf("unquoted argument contains '\"' or '#': %s", field)
+		}
+		fields = append(fields, field)
+		line = line[end:]
+	}
+}
+
+// quotedEnd finds the index after the closing quote of a
+// double-quoted string at the start of s, or returns -1.
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func withField(args []string, field string) []string {
+	if field == "" {
+		return args
+	}
+	return append(args, "in", field)
+}
+
+func formatRule(keyword string, args ...string) string {
+	parts := []string{keyword}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, "\"#") || strings.IndexFunc(arg, unicode.IsSpace) != -1 {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
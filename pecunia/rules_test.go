@@ -0,0 +1,52 @@
+package pecunia
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRulesRoundTrip(t *testing.T) {
+	rules := `# Example rules
+exclude "^ONLINE TRANSFER"
+category Groceries "SAFEWAY|TRADER JOE" # groceries
+category Rent ^1 in Check
+split COSTCO Groceries=2 Household=1
+replace "^PURCHASE AUTHORIZED ON [0-9/]+ " ""
+sign negative
+exclude-id a b
+`
+	filters, err := ParseRules(strings.NewReader(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters.CategoryFilters) != 2 || filters.CategoryFilters[1].Field != "Check" {
+		t.Fatalf("unexpected category filters: %+v", filters.CategoryFilters)
+	}
+	var buf bytes.Buffer
+	if err := WriteRules(&buf, filters); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseRules(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, filters) {
+		t.Errorf("round trip changed filters: %+v", parsed)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, rules := range []string{
+		`exclude "a"b`,
+		`exclude "a`,
+		`exclude a"b`,
+		`category Food "("`,
+		`unknown x`,
+	} {
+		if _, err := ParseRules(strings.NewReader(rules)); err == nil {
+			t.Errorf("%q: expected an error", rules)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package pecunia
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A Person is a member of a household who may share
+// expenses with others.
+type Person struct {
+	// Set by the data store.
+	ID string
+
+	Name string
+}
+
+// A ShareRule marks a transaction, or every transaction in
+// a category, as shared between people.
+type ShareRule struct {
+	// Set by the data store.
+	ID string
+
+	// Exactly one of TransactionID and Category is set. A
+	// rule for a transaction takes precedence over a rule
+	// for its category.
+	TransactionID string
+	Category      string
+
+	// The person who paid. If empty, the owner of the
+	// transaction's account paid.
+	PaidBy string
+
+	// The share of each person, keyed by person ID. Shares
+	// are relative, so {"a": 1, "b": 2} gives b two thirds.
+	Shares map[string]int
+}
+
+// Check makes sure the rule is valid and only refers to
+// the given people.
+func (s *ShareRule) Check(people []*Person) error {
+	if (s.TransactionID == "") == (s.Category == "") {
+		return errors.New("share rule needs either a transaction or a category")
+	}
+	ids := map[string]bool{}
+	for _, p := range people {
+		ids[p.ID] = true
+	}
+	if s.PaidBy != "" && !ids[s.PaidBy] {
+		return fmt.Errorf("unknown person: %s", s.PaidBy)
+	}
+	var total int
+	for id, share := range s.Shares {
+		if !ids[id] {
+			return fmt.Errorf("unknown person: %s", id)
+		}
+		if share < 0 {
+			return errors.New("shares must be non-negative")
+		}
+		total += share
+	}
+	if total == 0 {
+		return errors.New("share rule needs at least one positive share")
+	}
+	return nil
+}
+
+// A Settlement records a payment from one person to
+// another to settle shared expenses.
+type Settlement struct {
+	// Set by the data store.
+	ID string
+
+	Time   time.Time
+	FromID string
+	ToID   string
+
+	// The amount paid, in hundredths of a unit of Currency.
+	Amount int
+
+	// Currency is the currency of Amount. If empty,
+	// DefaultCurrency is used.
+	Currency string
+
+	Note string
+}
+
+// Check makes sure the settlement is valid and only refers
+// to the given people.
+func (s *Settlement) Check(people []*Person) error {
+	var fromOK, toOK bool
+	for _, p := range people {
+		fromOK = fromOK || p.ID == s.FromID
+		toOK = toOK || p.ID == s.ToID
+	}
+	if !fromOK {
+		return fmt.Errorf("unknown person: %s", s.FromID)
+	} else if !toOK {
+		return fmt.Errorf("unknown person: %s", s.ToID)
+	} else if s.FromID == s.ToID {
+		return errors.New("settlement must be between two different people")
+	} else if s.Amount <= 0 {
+		return errors.New("settlement amount must be positive")
+	}
+	return nil
+}
+
+// A SharedExpense is a transaction, or one split of a
+// transaction, which is shared between people.
+type SharedExpense struct {
+	Transaction *Transaction
+	RuleID      string
+	PaidBy      string
+
+	// The cost to each person, keyed by person ID. Refunds
+	// have negative costs.
+	Costs map[string]int
+}
+
+// A Debt is an amount which one person owes another.
+type Debt struct {
+	FromID string
+	ToID   string
+	Amount int
+}
+
+// SharedSummary is the result of SharedBalances.
+type SharedSummary struct {
+	Expenses []*SharedExpense
+
+	// Shared transactions for which nobody is known to have
+	// paid, since their accounts have no owner.
+	Unassigned []*Transaction
+
+	// The net balance of each person, keyed by person ID.
+	// A positive balance is owed to the person, and a
+	// negative balance is owed by them.
+	Balances map[string]int
+
+	// A small set of payments which would settle every
+	// balance.
+	Debts []*Debt
+}
+
+// SharedBalances computes the shared expenses and who owes
+// whom.
+//
+// Split transactions are expanded, so a category rule only
+// shares the splits in its category. Transfers should not
+// be included. Spending shared by a rule is divided by the
+// rule's shares, and each person owes their part to the
+// person who paid. Settlements are then subtracted.
+func SharedBalances(ts []*Transaction, accounts []*Account, rules []*ShareRule,
+	settlements []*Settlement) *SharedSummary {
+	owners := map[string]string{}
+	for _, a := range accounts {
+		owners[a.ID] = a.OwnerID
+	}
+	byTransaction := map[string]*ShareRule{}
+	byCategory := map[string]*ShareRule{}
+	for _, r := range rules {
+		if r.TransactionID != "" {
+			byTransaction[r.TransactionID] = r
+		} else {
+			byCategory[r.Category] = r
+		}
+	}
+
+	res := &SharedSummary{
+		Expenses:   []*SharedExpense{},
+		Unassigned: []*Transaction{},
+		Balances:   map[string]int{},
+		Debts:      []*Debt{},
+	}
+	for _, t := range ExpandSplits(ts) {
+		rule, ok := byTransaction[t.ID]
+		if !ok {
+			rule, ok = byCategory[t.Category]
+		}
+		if !ok {
+			continue
+		}
+		paidBy := rule.PaidBy
+		if paidBy == "" {
+			paidBy = owners[t.AccountID]
+		}
+		if paidBy == "" {
+			res.Unassigned = append(res.Unassigned, t)
+			continue
+		}
+
+		var ids []string
+		var weights []int
+		for id := range rule.Shares {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			weights = append(weights, rule.Shares[id])
+		}
+		costs, err := SplitAmount(-t.Amount, weights)
+		if err != nil {
+			continue
+		}
+		expense := &SharedExpense{
+			Transaction: t,
+			RuleID:      rule.ID,
+			PaidBy:      paidBy,
+			Costs:       map[string]int{},
+		}
+		for i, id := range ids {
+			expense.Costs[id] = costs[i]
+			res.Balances[id] -= costs[i]
+		}
+		res.Balances[paidBy] -= t.Amount
+		res.Expenses = append(res.Expenses, expense)
+	}
+
+	for _, s := range settlements {
+		res.Balances[s.FromID] += s.Amount
+		res.Balances[s.ToID] -= s.Amount
+	}
+	res.Debts = settleDebts(res.Balances)
+	return res
+}
+
+// settleDebts finds payments which settle the balances,
+// repeatedly paying the largest creditor from the largest
+// debtor.
+func settleDebts(balances map[string]int) []*Debt {
+	type entry struct {
+		ID      string
+		Balance int
+	}
+	var creditors, debtors []*entry
+	for id, balance := range balances {
+		if balance > 0 {
+			creditors = append(creditors, &entry{ID: id, Balance: balance})
+		} else if balance < 0 {
+			debtors = append(debtors, &entry{ID: id, Balance: -balance})
+		}
+	}
+	sortEntries := func(es []*entry) {
+		sort.Slice(es, func(i, j int) bool {
+			if es[i].Balance != es[j].Balance {
+				return es[i].Balance > es[j].Balance
+			}
+			return es[i].ID < es[j].ID
+		})
+	}
+	sortEntries(creditors)
+	sortEntries(debtors)
+
+	res := []*Debt{}
+	for i, j := 0, 0; i < len(debtors) && j < len(creditors); {
+		amount := debtors[i].Balance
+		if creditors[j].Balance < amount {
+			amount = creditors[j].Balance
+		}
+		res = append(res, &Debt{FromID: debtors[i].ID, ToID: creditors[j].ID, Amount: amount})
+		debtors[i].Balance -= amount
+		creditors[j].Balance -= amount
+		if debtors[i].Balance == 0 {
+			i++
+		}
+		if creditors[j].Balance == 0 {
+			j++
+		}
+	}
+	return res
+}
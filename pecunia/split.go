@@ -0,0 +1,91 @@
+package pecunia
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Split is one part of a transaction which has been
+// divided between multiple categories.
+type Split struct {
+	Category string
+	Amount   int
+}
+
+// SplitAmount divides an amount of cents proportionally to
+// a list of non-negative weights.
+//
+// The resulting amounts always sum exactly to amount, with
+// leftover cents going to the earliest parts.
+func SplitAmount(amount int, weights []int) ([]int, error) {
+	var total int
+	for _, w := range weights {
+		if w < 0 {
+			return nil, errors.New("split weights must be non-negative")
+		}
+		total += w
+	}
+	if total == 0 {
+		return nil, errors.New("split weights must not all be zero")
+	}
+	res := make([]int, len(weights))
+	remaining := amount
+	for i, w := range weights {
+		// Integer division truncates towards zero, so the
+		// remainder has the same sign as amount.
+		res[i] = amount * w / total
+		remaining -= res[i]
+	}
+	step := 1
+	if remaining < 0 {
+		step = -1
+	}
+	for i := 0; remaining != 0; i = (i + 1) % len(res) {
+		if weights[i] != 0 {
+			res[i] += step
+			remaining -= step
+		}
+	}
+	return res, nil
+}
+
+// CheckSplits makes sure that a list of splits is valid
+// for a transaction.
+func CheckSplits(t *Transaction, splits []*Split) error {
+	var total int
+	for _, s := range splits {
+		if s.Category == "" {
+			return errors.New("split has no category")
+		}
+		total += s.Amount
+	}
+	if len(splits) > 0 && total != t.Amount {
+		return fmt.Errorf("split amounts sum to %d but transaction amount is %d", total,
+			t.Amount)
+	}
+	return nil
+}
+
+// ExpandSplits replaces every split transaction with one
+// child transaction per split.
+//
+// Each child is a copy of its parent with the category
+// and amount of the split. Children keep the ID of their
+// parent, so they should only be used for totals.
+func ExpandSplits(ts []*Transaction) []*Transaction {
+	res := make([]*Transaction, 0, len(ts))
+	for _, t := range ts {
+		if len(t.Splits) == 0 {
+			res = append(res, t)
+			continue
+		}
+		for _, s := range t.Splits {
+			child := *t
+			child.Splits = nil
+			child.Category = s.Category
+			child.Amount = s.Amount
+			res = append(res, &child)
+		}
+	}
+	return res
+}
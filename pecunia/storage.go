@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 
@@ -22,6 +23,27 @@ type Account struct {
 	ID         string
 	Name       string
 	ImporterID string
+
+	// Kind is AccountAsset or AccountLiability. If empty,
+	// the account is an asset.
+	Kind string
+
+	// Currency is the currency of the account's balances
+	// and transactions. If empty, DefaultCurrency is used.
+	Currency string
+
+	// The balance before the first transaction, in cents.
+	// Balances are signed, so money owed on a liability is
+	// a negative balance.
+	OpeningBalance int
+
+	BalanceAssertions []*BalanceAssertion
+	Reconciliations   []*Reconciliation
+
+	// The ID of the Person who owns the account, if any.
+	// Shared expenses paid from the account are paid by
+	// this person.
+	OwnerID string
 }
 
 // Storage provides a system for saving transactions under
@@ -37,13 +59,19 @@ type Storage interface {
 	// account ID.
 	AddAccount(name, importerID string) (*Account, error)
 
+	// UpdateAccount replaces an account's attributes, such
+	// as its name or opening balance. The ID and importer
+	// of the account cannot be changed.
+	UpdateAccount(account *Account) error
+
 	// Transactions reads the current transaction list for
 	// an account.
 	Transactions(accountID string) ([]*Transaction, error)
 
 	// SetTransactions updates the transaction table under
 	// an account. This may be used to add, delete, and
-	// update transactions.
+	// update transactions. Overrides and transfers for
+	// deleted transactions are removed.
 	//
 	// Note that the IDs of the incoming transactions are
 	// important. If IDs are empty, then the transaction
@@ -59,7 +87,8 @@ type Storage interface {
 	SetAccountFilters(accountID string, mf *MultiFilter) error
 
 	// DeleteAccount deletes an account and its associated
-	// data.
+	// data, including overrides and transfers for its
+	// transactions.
 	DeleteAccount(accountID string) error
 
 	// GlobalFilters gets filters applied to all accounts.
@@ -68,6 +97,117 @@ type Storage interface {
 	// SetGlobalFilters updates the filters that are
 	// applied to all accounts.
 	SetGlobalFilters(mf *MultiFilter) error
+
+	// Overrides gets all of the per-transaction overrides,
+	// keyed by transaction ID.
+	Overrides() (map[string]*Override, error)
+
+	// SetOverride sets the override for a transaction.
+	// If o is nil, any existing override is removed.
+	//
+	// An error is returned when setting an override for a
+	// transaction which does not exist.
+	SetOverride(transactionID string, o *Override) error
+
+	// TransferSettings gets the settings for detecting
+	// transfers between accounts.
+	TransferSettings() (*TransferSettings, error)
+
+	// SetTransferSettings updates the settings for
+	// detecting transfers between accounts.
+	SetTransferSettings(ts *TransferSettings) error
+
+	// Transfers gets all of the detected, confirmed, and
+	// unlinked transfers between accounts.
+	Transfers() ([]*Transfer, error)
+
+	// SetTransfers updates the list of transfers.
+	SetTransfers(ts []*Transfer) error
+
+	// AddTransfers atomically adds new transfers to the
+	// list, skipping any which involve a transaction that
+	// no longer exists or is already part of a transfer.
+	//
+	// Returns all of the transfers, including the new ones.
+	AddTransfers(ts []*Transfer) ([]*Transfer, error)
+
+	// MerchantAliases gets the user-defined aliases used
+	// to determine the merchants of transactions.
+	MerchantAliases() ([]*MerchantAlias, error)
+
+	// SetMerchantAliases updates the merchant aliases.
+	SetMerchantAliases(aliases []*MerchantAlias) error
+
+	// AlertStates gets the states of alerts which have been
+	// acknowledged or dismissed, keyed by alert ID.
+	AlertStates() (map[string]string, error)
+
+	// SetAlertState updates the state of an alert.
+	SetAlertState(alertID, state string) error
+
+	// Budgets gets all of the budgets.
+	Budgets() ([]*Budget, error)
+
+	// SetBudgets updates the list of budgets.
+	//
+	// As with SetTransactions, budgets with empty IDs are
+	// assigned new IDs.
+	SetBudgets(budgets []*Budget) error
+
+	// ExchangeRates gets the stored exchange rates.
+	ExchangeRates() ([]*ExchangeRate, error)
+
+	// SetExchangeRates replaces the stored exchange rates.
+	SetExchangeRates(rates []*ExchangeRate) error
+
+	// TaxSettings gets the deductible categories and tags.
+	TaxSettings() (*TaxSettings, error)
+
+	// SetTaxSettings updates the deductible categories and
+	// tags.
+	SetTaxSettings(ts *TaxSettings) error
+
+	// People gets the members of the household.
+	People() ([]*Person, error)
+
+	// SetPeople updates the members of the household.
+	//
+	// People without IDs are assigned new IDs. People who
+	// are referenced by share rules, settlements, or
+	// accounts cannot be removed.
+	SetPeople(people []*Person) error
+
+	// ShareRules gets the rules for sharing expenses.
+	ShareRules() ([]*ShareRule, error)
+
+	// SetShareRules updates the rules for sharing expenses.
+	//
+	// Rules without IDs are assigned new IDs. There may be
+	// at most one rule for each category and transaction.
+	SetShareRules(rules []*ShareRule) error
+
+	// Settlements gets the payments between people which
+	// settle shared expenses.
+	Settlements() ([]*Settlement, error)
+
+	// SetSettlements updates the list of settlements.
+	//
+	// Settlements without IDs are assigned new IDs.
+	SetSettlements(settlements []*Settlement) error
+
+	// AddSettlement adds a settlement and assigns it a new
+	// ID.
+	AddSettlement(settlement *Settlement) error
+
+	// CompiledAccountFilters is like AccountFilters, but
+	// returns a compiled filter which is reused until the
+	// account's filters are changed.
+	CompiledAccountFilters(accountID string) (*CompiledFilter, error)
+
+	// CompiledGlobalFilters is like GlobalFilters, but
+	// returns a compiled filter which is reused until the
+	// global filters are changed.
+	CompiledGlobalFilters() (*CompiledFilter, error)
 }
 
 // AccountForID gets an account for a given ID.
@@ -84,18 +224,134 @@ func AccountForID(s Storage, accountID string) (*Account, error) {
 	return nil, fmt.Errorf("no account with ID: %s", accountID)
 }
 
+// AllTransactions merges the transactions from every
+// account, applying each account's filters, then the
+// global filters, and finally any overrides.
+//
+// Every resulting transaction has its AccountID and
+// Merchant set, as well as its Currency if the account
+// has one, and transactions which are part of a
+// linked transfer have their TransferID set.
+//
+// The result is sorted by time.
+func AllTransactions(s Storage) ([]*Transaction, error) {
+	transactions, err := accountTransactions(s)
+	if err != nil {
+		return nil, err
+	}
+	globalFilter, err := s.CompiledGlobalFilters()
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := s.Overrides()
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := s.Transfers()
+	if err != nil {
+		return nil, err
+	}
+	transactions = ApplyOverrides(globalFilter.Apply(transactions), overrides)
+	return MarkTransfers(transactions, transfers), nil
+}
+
+// GlobalFilterInput gets a transaction as it is seen by
+// the global filters, after account filters have been
+// applied but before global filters and overrides.
+func GlobalFilterInput(s Storage, transactionID string) (*Transaction, error) {
+	transactions, err := accountTransactions(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transactions {
+		if t.ID == transactionID {
+			return t, nil
+		}
+	}
+	return nil, errors.New("no transaction with ID: " + transactionID)
+}
+
+// accountTransactions gets the transactions from all
+// accounts with their account filters applied, sorted by
+// time.
+func accountTransactions(s Storage) ([]*Transaction, error) {
+	accts, err := s.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := s.MerchantAliases()
+	if err != nil {
+		return nil, err
+	}
+	merchants, err := NewMerchantNormalizer(aliases)
+	if err != nil {
+		return nil, err
+	}
+	globalFilter, err := s.CompiledGlobalFilters()
+	if err != nil {
+		return nil, err
+	}
+	var transactions []*Transaction
+	for _, acct := range accts {
+		trans, err := s.Transactions(acct.ID)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := s.CompiledAccountFilters(acct.ID)
+		if err != nil {
+			return nil, err
+		}
+		trans = withAccount(trans, acct)
+		if filter.usesFields || globalFilter.usesFields {
+			if importer, err := ImporterForID(acct.ImporterID); err == nil {
+				trans, err = AddFields(importer, trans)
+				if err != nil {
+					return nil, essentials.AddCtx("decode fields for account "+acct.Name, err)
+				}
+			}
+		}
+		trans = merchants.AddMerchants(trans)
+		transactions = append(transactions, filter.Apply(trans)...)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Time.UnixNano() < transactions[j].Time.UnixNano()
+	})
+	return transactions, nil
+}
+
+func withAccount(ts []*Transaction, a *Account) []*Transaction {
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		t1 := *t
+		t1.AccountID = a.ID
+		if t1.Currency == "" {
+			t1.Currency = a.Currency
+		}
+		res[i] = &t1
+	}
+	return res
+}
+
 // DirStorage is a storage system that using a directory
 // on the file system.
 type DirStorage struct {
 	Dir string
 
 	lock sync.RWMutex
+
+	// Compiled filters, keyed by account ID. The global
+	// filters are stored under the empty key.
+	compiledLock sync.Mutex
+	compiled     map[string]*CompiledFilter
 }
 
 func (d *DirStorage) Accounts() ([]*Account, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	return d.accounts()
+}
 
+func (d *DirStorage) accounts() ([]*Account, error) {
 	listing, err := ioutil.ReadDir(d.Dir)
 	if err != nil {
 		return nil, err
@@ -130,6 +386,24 @@ func (d *DirStorage) AddAccount(name, importerID string) (*Account, error) {
 	return account, nil
 }
 
+func (d *DirStorage) UpdateAccount(account *Account) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.checkAccountID(account.ID); err != nil {
+		return err
+	}
+	accountFile := fmt.Sprintf("account_%s.json", account.ID)
+	var old Account
+	if err := d.readFile(accountFile, &old); err != nil {
+		return err
+	}
+	if old.ImporterID != account.ImporterID {
+		return errors.New("cannot change the importer of an account")
+	}
+	return d.writeFile(accountFile, account)
+}
+
 func (d *DirStorage) Transactions(accountID string) ([]*Transaction, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
@@ -163,13 +437,19 @@ func (d *DirStorage) SetTransactions(accountID string, ts []*Transaction) error
 		}
 	}
 	name := fmt.Sprintf("transactions_%s.json", accountID)
-	return d.writeFile(name, ts)
+	if err := d.writeFile(name, ts); err != nil {
+		return err
+	}
+	return d.pruneDangling()
 }
 
 func (d *DirStorage) AccountFilters(accountID string) (*MultiFilter, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	return d.accountFilters(accountID)
+}
 
+func (d *DirStorage) accountFilters(accountID string) (*MultiFilter, error) {
 	if err := d.checkAccountID(accountID); err != nil {
 		return nil, err
 	}
@@ -186,7 +466,8 @@ func (d *DirStorage) AccountFilters(accountID string) (*MultiFilter, error) {
 }
 
 func (d *DirStorage) SetAccountFilters(accountID string, mf *MultiFilter) error {
-	if err := validateFilters(mf); err != nil {
+	compiled, err := mf.Compile()
+	if err != nil {
 		return err
 	}
 
@@ -196,7 +477,11 @@ func (d *DirStorage) SetAccountFilters(accountID string, mf *MultiFilter) error
 		return err
 	}
 	name := fmt.Sprintf("accountfilters_%s.json", accountID)
-	return d.writeFile(name, mf)
+	if err := d.writeFile(name, mf); err != nil {
+		return err
+	}
+	d.setCompiled(accountID, compiled)
+	return nil
 }
 
 func (d *DirStorage) DeleteAccount(accountID string) error {
@@ -222,14 +507,18 @@ func (d *DirStorage) DeleteAccount(accountID string) error {
 		// the data will never be found/used on their own.
 		os.Remove(filepath.Join(d.Dir, other))
 	}
+	d.setCompiled(accountID, nil)
 
-	return nil
+	return d.pruneDangling()
 }
 
 func (d *DirStorage) GlobalFilters() (*MultiFilter, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	return d.globalFilters()
+}
 
+func (d *DirStorage) globalFilters() (*MultiFilter, error) {
 	var filters MultiFilter
 	if err := d.readFile("global_filters.json", &filters); err != nil {
 		if os.IsNotExist(err) {
@@ -241,12 +530,599 @@ func (d *DirStorage) GlobalFilters() (*MultiFilter, error) {
 }
 
 func (d *DirStorage) SetGlobalFilters(mf *MultiFilter) error {
-	if err := validateFilters(mf); err != nil {
+	compiled, err := mf.Compile()
+	if err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.writeFile("global_filters.json", mf); err != nil {
 		return err
 	}
+	d.setCompiled("", compiled)
+	return nil
+}
+
+func (d *DirStorage) Overrides() (map[string]*Override, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.overrides()
+}
+
+func (d *DirStorage) overrides() (map[string]*Override, error) {
+	overrides := map[string]*Override{}
+	if err := d.readFile("overrides.json", &overrides); err != nil {
+		if os.IsNotExist(err) {
+			return overrides, nil
+		}
+		return nil, err
+	}
+	return overrides, nil
+}
+
+func (d *DirStorage) SetOverride(transactionID string, o *Override) error {
+	if transactionID == "" {
+		return errors.New("transaction ID is empty")
+	}
+	if err := validateID(transactionID); err != nil {
+		return err
+	}
+
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	return d.writeFile("global_filters.json", mf)
+
+	overrides, err := d.overrides()
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		delete(overrides, transactionID)
+	} else {
+		ids, err := d.transactionIDs()
+		if err != nil {
+			return err
+		}
+		if !ids[transactionID] {
+			return errors.New("no transaction with ID: " + transactionID)
+		}
+		overrides[transactionID] = o
+	}
+	return d.writeFile("overrides.json", overrides)
+}
+
+// transactionIDs gets the IDs of every stored transaction
+// in every account.
+func (d *DirStorage) transactionIDs() (map[string]bool, error) {
+	listing, err := ioutil.ReadDir(d.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, item := range listing {
+		name := item.Name()
+		if !strings.HasPrefix(name, "transactions_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		var transactions []*Transaction
+		if err := d.readFile(name, &transactions); err != nil {
+			return nil, err
+		}
+		for _, t := range transactions {
+			ids[t.ID] = true
+		}
+	}
+	return ids, nil
+}
+
+// pruneDangling removes overrides and transfers which
+// refer to transactions that no longer exist.
+func (d *DirStorage) pruneDangling() error {
+	ids, err := d.transactionIDs()
+	if err != nil {
+		return err
+	}
+	overrides, err := d.overrides()
+	if err != nil {
+		return err
+	}
+	var changed bool
+	for id := range overrides {
+		if !ids[id] {
+			delete(overrides, id)
+			changed = true
+		}
+	}
+	if changed {
+		if err := d.writeFile("overrides.json", overrides); err != nil {
+			return err
+		}
+	}
+
+	transfers, err := d.transfers()
+	if err != nil {
+		return err
+	}
+	kept := []*Transfer{}
+	for _, t := range transfers {
+		if ids[t.FromID] && ids[t.ToID] {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(transfers) {
+		return nil
+	}
+	return d.writeFile("transfers.json", kept)
+}
+
+func (d *DirStorage) TransferSettings() (*TransferSettings, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	settings := DefaultTransferSettings()
+	if err := d.readFile("transfer_settings.json", settings); err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (d *DirStorage) SetTransferSettings(ts *TransferSettings) error {
+	if _, err := ts.Compile(); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.writeFile("transfer_settings.json", ts)
+}
+
+func (d *DirStorage) Transfers() ([]*Transfer, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.transfers()
+}
+
+func (d *DirStorage) transfers() ([]*Transfer, error) {
+	var transfers []*Transfer
+	if err := d.readFile("transfers.json", &transfers); err != nil {
+		if os.IsNotExist(err) {
+			return []*Transfer{}, nil
+		}
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (d *DirStorage) SetTransfers(ts []*Transfer) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, t := range ts {
+		if t.ID == "" {
+			t.ID = uuid.New().String()
+		}
+	}
+	return d.writeFile("transfers.json", ts)
+}
+
+func (d *DirStorage) AddTransfers(ts []*Transfer) ([]*Transfer, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	transfers, err := d.transfers()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := d.transactionIDs()
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for _, t := range transfers {
+		used[t.FromID] = true
+		used[t.ToID] = true
+	}
+	var added bool
+	for _, t := range ts {
+		if used[t.FromID] || used[t.ToID] || !ids[t.FromID] || !ids[t.ToID] {
+			continue
+		}
+		used[t.FromID] = true
+		used[t.ToID] = true
+		if t.ID == "" {
+			t.ID = uuid.New().String()
+		}
+		transfers = append(transfers, t)
+		added = true
+	}
+	if !added {
+		return transfers, nil
+	}
+	return transfers, d.writeFile("transfers.json", transfers)
+}
+
+func (d *DirStorage) MerchantAliases() ([]*MerchantAlias, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	var aliases []*MerchantAlias
+	if err := d.readFile("merchant_aliases.json", &aliases); err != nil {
+		if os.IsNotExist(err) {
+			return []*MerchantAlias{}, nil
+		}
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (d *DirStorage) SetMerchantAliases(aliases []*MerchantAlias) error {
+	if _, err := NewMerchantNormalizer(aliases); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.writeFile("merchant_aliases.json", aliases)
+}
+
+func (d *DirStorage) AlertStates() (map[string]string, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.alertStates()
+}
+
+func (d *DirStorage) alertStates() (map[string]string, error) {
+	states := map[string]string{}
+	if err := d.readFile("alert_states.json", &states); err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, err
+	}
+	return states, nil
+}
+
+func (d *DirStorage) SetAlertState(alertID, state string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	states, err := d.alertStates()
+	if err != nil {
+		return err
+	}
+	if state == AlertNew {
+		delete(states, alertID)
+	} else {
+		states[alertID] = state
+	}
+	return d.writeFile("alert_states.json", states)
+}
+
+func (d *DirStorage) Budgets() ([]*Budget, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	var budgets []*Budget
+	if err := d.readFile("budgets.json", &budgets); err != nil {
+		if os.IsNotExist(err) {
+			return []*Budget{}, nil
+		}
+		return nil, err
+	}
+	return budgets, nil
+}
+
+func (d *DirStorage) SetBudgets(budgets []*Budget) error {
+	for _, b := range budgets {
+		if err := b.Check(); err != nil {
+			return err
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, b := range budgets {
+		if b.ID == "" {
+			b.ID = uuid.New().String()
+		}
+	}
+	return d.writeFile("budgets.json", budgets)
+}
+
+func (d *DirStorage) ExchangeRates() ([]*ExchangeRate, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	var rates []*ExchangeRate
+	if err := d.readFile("exchange_rates.json", &rates); err != nil {
+		if os.IsNotExist(err) {
+			return []*ExchangeRate{}, nil
+		}
+		return nil, err
+	}
+	return rates, nil
+}
+
+func (d *DirStorage) SetExchangeRates(rates []*ExchangeRate) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.writeFile("exchange_rates.json", rates)
+}
+
+func (d *DirStorage) TaxSettings() (*TaxSettings, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	settings := DefaultTaxSettings()
+	if err := d.readFile("tax_settings.json", settings); err != nil {
+		if os.IsNotExist(err) {
+			return settings, nil
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (d *DirStorage) SetTaxSettings(ts *TaxSettings) error {
+	if err := ts.Check(); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.writeFile("tax_settings.json", ts)
+}
+
+func (d *DirStorage) People() ([]*Person, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.people()
+}
+
+func (d *DirStorage) people() ([]*Person, error) {
+	var people []*Person
+	if err := d.readFile("people.json", &people); err != nil {
+		if os.IsNotExist(err) {
+			return []*Person{}, nil
+		}
+		return nil, err
+	}
+	return people, nil
+}
+
+func (d *DirStorage) SetPeople(people []*Person) error {
+	ids := map[string]bool{}
+	for _, p := range people {
+		if p.Name == "" {
+			return errors.New("person has no name")
+		}
+		if p.ID != "" {
+			if ids[p.ID] {
+				return fmt.Errorf("duplicate person ID: %s", p.ID)
+			}
+			ids[p.ID] = true
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	referenced, err := d.referencedPeople()
+	if err != nil {
+		return err
+	}
+	for id, usage := range referenced {
+		if !ids[id] {
+			return fmt.Errorf("cannot remove person %s: referenced by %s", id, usage)
+		}
+	}
+
+	for _, p := range people {
+		if p.ID == "" {
+			p.ID = uuid.New().String()
+		}
+	}
+	return d.writeFile("people.json", people)
+}
+
+// referencedPeople finds the people referenced by share
+// rules, settlements, and account owners, mapping each
+// person ID to a description of what refers to it.
+func (d *DirStorage) referencedPeople() (map[string]string, error) {
+	res := map[string]string{}
+	rules, err := d.shareRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if r.PaidBy != "" {
+			res[r.PaidBy] = "a share rule"
+		}
+		for id := range r.Shares {
+			res[id] = "a share rule"
+		}
+	}
+	settlements, err := d.settlements()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settlements {
+		res[s.FromID] = "a settlement"
+		res[s.ToID] = "a settlement"
+	}
+	accounts, err := d.accounts()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accounts {
+		if a.OwnerID != "" {
+			res[a.OwnerID] = "account " + a.Name
+		}
+	}
+	return res, nil
+}
+
+func (d *DirStorage) ShareRules() ([]*ShareRule, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.shareRules()
+}
+
+func (d *DirStorage) shareRules() ([]*ShareRule, error) {
+	var rules []*ShareRule
+	if err := d.readFile("share_rules.json", &rules); err != nil {
+		if os.IsNotExist(err) {
+			return []*ShareRule{}, nil
+		}
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (d *DirStorage) SetShareRules(rules []*ShareRule) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	people, err := d.people()
+	if err != nil {
+		return err
+	}
+	targets := map[string]bool{}
+	for _, r := range rules {
+		if err := r.Check(people); err != nil {
+			return err
+		}
+		target := "category " + r.Category
+		if r.TransactionID != "" {
+			target = "transaction " + r.TransactionID
+		}
+		if targets[target] {
+			return fmt.Errorf("duplicate share rule for %s", target)
+		}
+		targets[target] = true
+	}
+	for _, r := range rules {
+		if r.ID == "" {
+			r.ID = uuid.New().String()
+		}
+	}
+	return d.writeFile("share_rules.json", rules)
+}
+
+func (d *DirStorage) Settlements() ([]*Settlement, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.settlements()
+}
+
+func (d *DirStorage) settlements() ([]*Settlement, error) {
+	var settlements []*Settlement
+	if err := d.readFile("settlements.json", &settlements); err != nil {
+		if os.IsNotExist(err) {
+			return []*Settlement{}, nil
+		}
+		return nil, err
+	}
+	return settlements, nil
+}
+
+func (d *DirStorage) SetSettlements(settlements []*Settlement) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	people, err := d.people()
+	if err != nil {
+		return err
+	}
+	for _, s := range settlements {
+		if err := s.Check(people); err != nil {
+			return err
+		}
+	}
+	for _, s := range settlements {
+		if s.ID == "" {
+			s.ID = uuid.New().String()
+		}
+	}
+	return d.writeFile("settlements.json", settlements)
+}
+
+func (d *DirStorage) AddSettlement(settlement *Settlement) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	people, err := d.people()
+	if err != nil {
+		return err
+	}
+	if err := settlement.Check(people); err != nil {
+		return err
+	}
+	settlements, err := d.settlements()
+	if err != nil {
+		return err
+	}
+	settlement.ID = uuid.New().String()
+	return d.writeFile("settlements.json", append(settlements, settlement))
+}
+
+func (d *DirStorage) CompiledAccountFilters(accountID string) (*CompiledFilter, error) {
+	// Hold the read lock while compiling so that a concurrent
+	// update cannot be overwritten by stale filters.
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if res := d.getCompiled(accountID); res != nil {
+		return res, nil
+	}
+	mf, err := d.accountFilters(accountID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := mf.Compile()
+	if err != nil {
+		return nil, err
+	}
+	d.setCompiled(accountID, res)
+	return res, nil
+}
+
+func (d *DirStorage) CompiledGlobalFilters() (*CompiledFilter, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if res := d.getCompiled(""); res != nil {
+		return res, nil
+	}
+	mf, err := d.globalFilters()
+	if err != nil {
+		return nil, err
+	}
+	res, err := mf.Compile()
+	if err != nil {
+		return nil, err
+	}
+	d.setCompiled("", res)
+	return res, nil
+}
+
+func (d *DirStorage) getCompiled(key string) *CompiledFilter {
+	d.compiledLock.Lock()
+	defer d.compiledLock.Unlock()
+	return d.compiled[key]
+}
+
+func (d *DirStorage) setCompiled(key string, c *CompiledFilter) {
+	d.compiledLock.Lock()
+	defer d.compiledLock.Unlock()
+	if c == nil {
+		delete(d.compiled, key)
+		return
+	}
+	if d.compiled == nil {
+		d.compiled = map[string]*CompiledFilter{}
+	}
+	d.compiled[key] = c
 }
 
 func (d *DirStorage) readFile(name string, out interface{}) error {
@@ -291,22 +1167,3 @@ func validateID(id string) error {
 	}
 	return nil
 }
-
-func validateFilters(m *MultiFilter) error {
-	for _, p := range m.PatternFilters {
-		if _, err := regexp.CompilePOSIX(p.Pattern); err != nil {
-			return essentials.AddCtx("parse pattern filter", err)
-		}
-	}
-	for _, c := range m.CategoryFilters {
-		if _, err := regexp.CompilePOSIX(c.Pattern); err != nil {
-			return essentials.AddCtx("parse category filter", err)
-		}
-	}
-	for _, r := range m.ReplaceFilters {
-		if _, err := regexp.CompilePOSIX(r.Pattern); err != nil {
-			return essentials.AddCtx("parse replace filter", err)
-		}
-	}
-	return nil
-}
@@ -0,0 +1,42 @@
+package pecunia
+
+import (
+	"fmt"
+	"time"
+)
+
+// TaxSettings lists the categories and tags of deductible
+// transactions, such as charity or medical expenses.
+type TaxSettings struct {
+	Categories []string
+	Tags       []string
+
+	// The month in which each tax year starts, from 1 for
+	// January to 12 for December.
+	YearStartMonth int
+}
+
+// DefaultTaxSettings returns the settings which are used
+// when none have been saved.
+func DefaultTaxSettings() *TaxSettings {
+	return &TaxSettings{
+		Categories:     []string{},
+		Tags:           []string{},
+		YearStartMonth: 1,
+	}
+}
+
+// Check makes sure the settings are valid.
+func (t *TaxSettings) Check() error {
+	if t.YearStartMonth < 1 || t.YearStartMonth > 12 {
+		return fmt.Errorf("year start month must be from 1 to 12, not %d", t.YearStartMonth)
+	}
+	return nil
+}
+
+// YearBounds gets the time range [start, end) of the tax
+// year which starts in the given calendar year.
+func (t *TaxSettings) YearBounds(year int) (time.Time, time.Time) {
+	start := time.Date(year, time.Month(t.YearStartMonth), 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(1, 0, 0)
+}
@@ -3,16 +3,60 @@ package pecunia
 import "time"
 
 type Transaction struct {
-	Time        time.Time
+	Time time.Time
+
+	// Amount is in hundredths of a unit of Currency.
 	Amount      int
+	Currency    string
 	Description string
 
 	// Set by an importer.
 	Extra string
 
+	// Raw fields parsed from Extra by a FieldImporter.
+	// These are filled in before filters are applied, but
+	// only if a filter uses them. They are never encoded,
+	// since they duplicate Extra.
+	Fields map[string]string `json:"-"`
+
 	// Set by the data store.
 	ID string
 
+	// Set when an account is reconciled. Reconciled
+	// transactions cannot be changed by imports.
+	Reconciled bool
+
+	// Set when transactions from all accounts are merged.
+	AccountID  string
+	TransferID string
+	Merchant   string
+
 	// May be set by filters.
 	Category string
+
+	// May be set by overrides.
+	Tags []string
+
+	// May be set by split filters or overrides. If set,
+	// the amounts of the splits sum to Amount.
+	Splits []*Split
+}
+
+// FieldValue gets the value of a named field from Fields,
+// the description if the name is empty, or the merchant if
+// the name is MerchantField.
+func (t *Transaction) FieldValue(name string) string {
+	switch name {
+	case "":
+		return t.Description
+	case MerchantField:
+		return t.Merchant
+	}
+	return t.Fields[name]
+}
+
+// isImporterField checks if a field name passed to
+// FieldValue refers to one of the Fields.
+func isImporterField(name string) bool {
+	return name != "" && name != MerchantField
 }
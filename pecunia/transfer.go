@@ -0,0 +1,238 @@
+package pecunia
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/essentials"
+)
+
+// Statuses for a Transfer.
+const (
+	TransferDetected  = "detected"
+	TransferConfirmed = "confirmed"
+	TransferUnlinked  = "unlinked"
+)
+
+// A Transfer pairs a withdrawal from one account with a
+// deposit into another account, such as a credit card
+// payment made from a checking account.
+type Transfer struct {
+	// Set by the data store.
+	ID string
+
+	// IDs of the withdrawal and deposit transactions.
+	FromID string
+	ToID   string
+
+	// One of TransferDetected, TransferConfirmed, or
+	// TransferUnlinked. Unlinked transfers are kept so that
+	// the pair is not detected again.
+	Status string
+}
+
+// Linked checks if the transfer's transactions should be
+// treated as a transfer.
+func (t *Transfer) Linked() bool {
+	return t.Status != TransferUnlinked
+}
+
+// TransferSettings controls how transfers are detected.
+type TransferSettings struct {
+	// The maximum number of days between the withdrawal
+	// and the deposit.
+	WindowDays int
+
+	// If non-empty, at least one of the two transactions
+	// must have a description matching one of these
+	// regular expressions.
+	Patterns []string
+}
+
+// DefaultTransferSettings returns the settings which are
+// used when none have been saved.
+func DefaultTransferSettings() *TransferSettings {
+	return &TransferSettings{WindowDays: 5}
+}
+
+// Compile compiles the patterns of the settings.
+func (t *TransferSettings) Compile() ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range t.Patterns {
+		expr, err := regexp.CompilePOSIX(p)
+		if err != nil {
+			return nil, essentials.AddCtx("parse transfer pattern", err)
+		}
+		res = append(res, expr)
+	}
+	return res, nil
+}
+
+// DetectTransfers finds new transfers between the merged
+// transactions of different accounts.
+//
+// Transactions which are already part of an existing
+// transfer are not matched again, and neither are pairs
+// which were previously unlinked. Each withdrawal is paired
+// with the closest matching deposit in time.
+func DetectTransfers(ts []*Transaction, settings *TransferSettings,
+	existing []*Transfer) ([]*Transfer, error) {
+	patterns, err := settings.Compile()
+	if err != nil {
+		return nil, err
+	}
+	matchesPattern := func(t *Transaction) bool {
+		for _, p := range patterns {
+			if p.MatchString(t.Description) {
+				return true
+			}
+		}
+		return len(patterns) == 0
+	}
+
+	used := map[string]bool{}
+	unlinked := map[[2]string]bool{}
+	for _, t := range existing {
+		if t.Linked() {
+			used[t.FromID] = true
+			used[t.ToID] = true
+		} else {
+			unlinked[[2]string{t.FromID, t.ToID}] = true
+		}
+	}
+
+	deposits := map[int][]*Transaction{}
+	for _, t := range ts {
+		if t.Amount > 0 && !used[t.ID] {
+			deposits[t.Amount] = append(deposits[t.Amount], t)
+		}
+	}
+
+	window := time.Duration(settings.WindowDays) * 24 * time.Hour
+	var res []*Transfer
+	for _, from := range ts {
+		if from.Amount >= 0 || used[from.ID] {
+			continue
+		}
+		var best *Transaction
+		var bestDist time.Duration
+		for _, to := range deposits[-from.Amount] {
+			if used[to.ID] || to.AccountID == from.AccountID ||
+				unlinked[[2]string{from.ID, to.ID}] {
+				continue
+			}
+			if !matchesPattern(from) && !matchesPattern(to) {
+				continue
+			}
+			dist := to.Time.Sub(from.Time)
+			if dist < 0 {
+				dist = -dist
+			}
+			if dist <= window && (best == nil || dist < bestDist) {
+				best, bestDist = to, dist
+			}
+		}
+		if best != nil {
+			used[from.ID] = true
+			used[best.ID] = true
+			res = append(res, &Transfer{
+				FromID: from.ID,
+				ToID:   best.ID,
+				Status: TransferDetected,
+			})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].FromID < res[j].FromID
+	})
+	return res, nil
+}
+
+// MarkTransfers sets the TransferID of every transaction
+// which is part of a linked transfer.
+//
+// Transactions which are modified are copied, and the
+// input slice and its transactions are not modified.
+func MarkTransfers(ts []*Transaction, transfers []*Transfer) []*Transaction {
+	ids := map[string]string{}
+	for _, t := range transfers {
+		if t.Linked() {
+			ids[t.FromID] = t.ID
+			ids[t.ToID] = t.ID
+		}
+	}
+	res := make([]*Transaction, len(ts))
+	for i, t := range ts {
+		if id, ok := ids[t.ID]; ok {
+			t1 := *t
+			t1.TransferID = id
+			res[i] = &t1
+		} else {
+			res[i] = t
+		}
+	}
+	return res
+}
+
+// WithoutTransfers removes every transaction which is part
+// of a transfer, for use in spending summaries.
+func WithoutTransfers(ts []*Transaction) []*Transaction {
+	res := make([]*Transaction, 0, len(ts))
+	for _, t := range ts {
+		if t.TransferID == "" {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// UpdateTransfers detects new transfers among all of the
+// transactions in s and saves them alongside the existing
+// transfers.
+//
+// Returns all of the transfers, including the new ones.
+func UpdateTransfers(s Storage) ([]*Transfer, error) {
+	transactions, err := AllTransactions(s)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.TransferSettings()
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := s.Transfers()
+	if err != nil {
+		return nil, err
+	}
+	newTransfers, err := DetectTransfers(transactions, settings, transfers)
+	if err != nil {
+		return nil, err
+	}
+	if len(newTransfers) == 0 {
+		return transfers, nil
+	}
+
+	// Another update may have saved transfers since they
+	// were read, so they are merged by the storage.
+	return s.AddTransfers(newTransfers)
+}
+
+// SetTransferStatus changes the status of a transfer in s.
+func SetTransferStatus(s Storage, transferID, status string) (*Transfer, error) {
+	if status != TransferDetected && status != TransferConfirmed && status != TransferUnlinked {
+		return nil, fmt.Errorf("unknown transfer status: %s", status)
+	}
+	transfers, err := s.Transfers()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transfers {
+		if t.ID == transferID {
+			t.Status = status
+			return t, s.SetTransfers(transfers)
+		}
+	}
+	return nil, fmt.Errorf("no transfer with ID: %s", transferID)
+}
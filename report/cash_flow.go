@@ -0,0 +1,187 @@
+package report
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// A CashFlowPeriod summarizes income and spending over
+// one time period.
+type CashFlowPeriod struct {
+	Key   string
+	Start time.Time
+	End   time.Time
+
+	Income   int
+	Spending int
+	Net      int
+
+	// Totals for each category, from most to least
+	// spending.
+	Categories []*Group
+
+	// Changes since the previous period and since the same
+	// period a year earlier. These are nil if the earlier
+	// period is not covered by the transactions.
+	PreviousChange *CashFlowDelta
+	YearChange     *CashFlowDelta
+}
+
+// A CashFlowDelta compares a period to an earlier period.
+type CashFlowDelta struct {
+	Key string
+
+	Income   *Change
+	Spending *Change
+	Net      *Change
+
+	// Changes in spending per category.
+	Categories map[string]*Change
+}
+
+// A Change is the difference between two amounts.
+type Change struct {
+	Amount int
+
+	// Percent is the relative change in percent, or nil if
+	// the earlier amount was zero.
+	Percent *float64
+}
+
+func newChange(old, cur int) *Change {
+	res := &Change{Amount: cur - old}
+	if old != 0 {
+		percent := 100 * float64(cur-old) / float64(old)
+		if old < 0 {
+			percent = -percent
+		}
+		res.Percent = &percent
+	}
+	return res
+}
+
+// CashFlow computes the income and spending for every
+// period of a time grouping (ByDay, ByWeek, ByMonth, or
+// ByYear) which overlaps [start, end).
+//
+// If start or end is zero, the range begins or ends with
+// the transactions. Transactions outside of the range are
+// still used to compute changes from earlier periods.
+func CashFlow(ts []*pecunia.Transaction, grouping string, start,
+	end time.Time) ([]*CashFlowPeriod, error) {
+	if !IsTimeGrouping(grouping) {
+		return nil, errors.New("cash flow requires a time grouping")
+	}
+	if len(ts) == 0 {
+		return []*CashFlowPeriod{}, nil
+	}
+
+	byPeriod := map[string][]*pecunia.Transaction{}
+	first, last := ts[0].Time, ts[0].Time
+	for _, t := range ts {
+		key := PeriodKey(t.Time, grouping)
+		byPeriod[key] = append(byPeriod[key], t)
+		if t.Time.Before(first) {
+			first = t.Time
+		}
+		if t.Time.After(last) {
+			last = t.Time
+		}
+	}
+
+	periods := map[string]*CashFlowPeriod{}
+	var ordered []*CashFlowPeriod
+	for p := PeriodStart(first, grouping); !p.After(last); p = NextPeriod(p, grouping) {
+		period := &CashFlowPeriod{
+			Key:   PeriodKey(p, grouping),
+			Start: p,
+			End:   NextPeriod(p, grouping),
+		}
+		groups, _ := GroupBy(byPeriod[period.Key], ByCategory)
+		period.Categories = groups
+		for _, g := range groups {
+			period.Income += g.Income
+			period.Spending += g.Spending
+			period.Net += g.Net
+		}
+		periods[period.Key] = period
+		ordered = append(ordered, period)
+	}
+
+	res := []*CashFlowPeriod{}
+	for i, period := range ordered {
+		if !start.IsZero() && !period.End.After(start) {
+			continue
+		}
+		if !end.IsZero() && !period.Start.Before(end) {
+			continue
+		}
+		if i > 0 {
+			period.PreviousChange = cashFlowDelta(ordered[i-1], period)
+		}
+		yearAgo := period.Start.AddDate(-1, 0, 0)
+		if grouping == ByWeek {
+			yearAgo = period.Start.AddDate(0, 0, -7*52)
+		}
+		if old, ok := periods[PeriodKey(yearAgo, grouping)]; ok {
+			period.YearChange = cashFlowDelta(old, period)
+		}
+		res = append(res, period)
+	}
+	return res, nil
+}
+
+func cashFlowDelta(old, cur *CashFlowPeriod) *CashFlowDelta {
+	res := &CashFlowDelta{
+		Key:        old.Key,
+		Income:     newChange(old.Income, cur.Income),
+		Spending:   newChange(old.Spending, cur.Spending),
+		Net:        newChange(old.Net, cur.Net),
+		Categories: map[string]*Change{},
+	}
+	oldSpending := map[string]int{}
+	for _, g := range old.Categories {
+		oldSpending[g.Key] = g.Spending
+	}
+	for _, g := range cur.Categories {
+		res.Categories[g.Key] = newChange(oldSpending[g.Key], g.Spending)
+		delete(oldSpending, g.Key)
+	}
+	for key, spending := range oldSpending {
+		res.Categories[key] = newChange(spending, 0)
+	}
+	return res
+}
+
+// PeriodStart gets the beginning of the time period which
+// contains t for a time grouping.
+// Weeks begin on Mondays, as with ISO weeks.
+func PeriodStart(t time.Time, grouping string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch grouping {
+	case ByWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case ByMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	case ByYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return day
+}
+
+// NextPeriod gets the start of the time period after the
+// one which starts at t.
+func NextPeriod(t time.Time, grouping string) time.Time {
+	switch grouping {
+	case ByWeek:
+		return t.AddDate(0, 0, 7)
+	case ByMonth:
+		return t.AddDate(0, 1, 0)
+	case ByYear:
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
@@ -0,0 +1,281 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// forecastHistoryDays is the number of days before the
+// current month used to estimate the variability of daily
+// spending.
+const forecastHistoryDays = 90
+
+// A Band is a range of likely values for a projection,
+// roughly covering a 95% confidence interval.
+type Band struct {
+	Low  int
+	High int
+}
+
+// A CategoryForecast projects the spending in a category
+// at the end of the current month.
+type CategoryForecast struct {
+	Category string
+
+	// Spending so far this month.
+	Spent int
+
+	// Expected recurring charges which have not happened
+	// yet this month.
+	UpcomingRecurring int
+
+	Projected int
+	Band      Band
+
+	// The monthly budget limit for the category, if it has
+	// a monthly budget.
+	BudgetLimit *int
+
+	// OverBudget is set if the projection exceeds the
+	// budget, and AtRisk is set if the high end of the band
+	// does.
+	OverBudget bool
+	AtRisk     bool
+}
+
+// An AccountForecast projects the balance of an account at
+// the end of the current month.
+type AccountForecast struct {
+	AccountID string
+
+	Balance           int
+	UpcomingRecurring int
+
+	Projected int
+	Band      Band
+}
+
+// A forecastSeries summarizes a stream of amounts for one
+// category or account during the forecast.
+type forecastSeries struct {
+	// Total amount so far this month.
+	MonthTotal int
+
+	// Amount so far this month, excluding recurring
+	// transactions.
+	MonthNonRecurring int
+
+	// Non-recurring amounts for each day of the history.
+	DailyHistory map[int]int
+
+	Upcoming int
+}
+
+// forecastWindow describes the month containing now.
+type forecastWindow struct {
+	Now          time.Time
+	MonthStart   time.Time
+	MonthEnd     time.Time
+	HistoryStart time.Time
+
+	DaysElapsed   float64
+	DaysRemaining float64
+}
+
+func newForecastWindow(now time.Time) *forecastWindow {
+	monthStart := PeriodStart(now, ByMonth)
+	monthEnd := NextPeriod(monthStart, ByMonth)
+	return &forecastWindow{
+		Now:           now,
+		MonthStart:    monthStart,
+		MonthEnd:      monthEnd,
+		HistoryStart:  monthStart.AddDate(0, 0, -forecastHistoryDays),
+		DaysElapsed:   math.Max(1, now.Sub(monthStart).Hours()/24),
+		DaysRemaining: math.Max(0, monthEnd.Sub(now).Hours()/24),
+	}
+}
+
+// add records a transaction amount in the series.
+func (f *forecastWindow) add(s *forecastSeries, t *pecunia.Transaction, amount int,
+	recurring bool) {
+	if t.Time.After(f.Now) {
+		return
+	}
+	if !t.Time.Before(f.MonthStart) {
+		s.MonthTotal += amount
+		if !recurring {
+			s.MonthNonRecurring += amount
+		}
+	} else if !t.Time.Before(f.HistoryStart) && !recurring {
+		day := int(t.Time.Sub(f.HistoryStart).Hours() / 24)
+		s.DailyHistory[day] += amount
+	}
+}
+
+// project computes the projected value and band, given a
+// starting value for the month.
+func (f *forecastWindow) project(s *forecastSeries, base int) (int, Band) {
+	rate := float64(s.MonthNonRecurring) / f.DaysElapsed
+	projected := float64(base) + rate*f.DaysRemaining + float64(s.Upcoming)
+
+	var sum, sqSum float64
+	for day := 0; day < forecastHistoryDays; day++ {
+		x := float64(s.DailyHistory[day])
+		sum += x
+		sqSum += x * x
+	}
+	mean := sum / forecastHistoryDays
+	std := math.Sqrt(math.Max(0, sqSum/forecastHistoryDays-mean*mean))
+	margin := 1.96 * std * math.Sqrt(f.DaysRemaining)
+
+	return int(math.Round(projected)), Band{
+		Low:  int(math.Round(projected - margin)),
+		High: int(math.Round(projected + margin)),
+	}
+}
+
+// ForecastCategories projects the spending of every
+// category at the end of the month containing now.
+//
+// The projection is the spending so far, plus the daily
+// rate of non-recurring spending so far this month for the
+// rest of the month, plus any recurring charges expected
+// before the end of the month. The band is based on the
+// variability of daily spending over the previous months.
+// The transactions should not include transfers.
+func ForecastCategories(ts []*pecunia.Transaction, recurring []*pecunia.Recurring,
+	budgets []*pecunia.Budget, now time.Time) []*CategoryForecast {
+	window := newForecastWindow(now)
+	recurringIDs, categories := recurringInfo(ts, recurring)
+
+	series := map[string]*forecastSeries{}
+	getSeries := func(category string) *forecastSeries {
+		if s, ok := series[category]; ok {
+			return s
+		}
+		s := &forecastSeries{DailyHistory: map[int]int{}}
+		series[category] = s
+		return s
+	}
+	for _, t := range pecunia.ExpandSplits(ts) {
+		window.add(getSeries(t.Category), t, -t.Amount, recurringIDs[t.ID])
+	}
+	for i, r := range recurring {
+		count := r.UpcomingCount(now, window.MonthEnd)
+		if r.AverageAmount < 0 && count > 0 {
+			getSeries(categories[i]).Upcoming -= r.AverageAmount * count
+		}
+	}
+
+	monthlyBudgets := map[string]int{}
+	for _, b := range budgets {
+		if b.Period != pecunia.IntervalMonthly {
+			continue
+		}
+		periods := pecunia.BudgetPeriods(b, ts, now, now.Add(time.Second))
+		if len(periods) == 1 {
+			monthlyBudgets[b.Category] += periods[0].Limit
+		}
+	}
+
+	res := []*CategoryForecast{}
+	for _, group := range sortedKeys(series) {
+		s := series[group]
+		projected, band := window.project(s, s.MonthTotal)
+		if band.Low < s.MonthTotal+s.Upcoming {
+			band.Low = s.MonthTotal + s.Upcoming
+		}
+		forecast := &CategoryForecast{
+			Category:          group,
+			Spent:             s.MonthTotal,
+			UpcomingRecurring: s.Upcoming,
+			Projected:         projected,
+			Band:              band,
+		}
+		if limit, ok := monthlyBudgets[group]; ok {
+			forecast.BudgetLimit = &limit
+			forecast.OverBudget = projected > limit
+			forecast.AtRisk = band.High > limit
+		}
+		if forecast.Projected != 0 || forecast.BudgetLimit != nil {
+			res = append(res, forecast)
+		}
+	}
+	return res
+}
+
+// ForecastAccounts projects the balance of every account
+// at the end of the month containing now.
+//
+// The transactions map account IDs to the unfiltered
+// transactions of each account. Projections are made in
+// the same way as ForecastCategories, using net cash flow
+// rather than spending.
+func ForecastAccounts(accounts []*pecunia.Account,
+	transactions map[string][]*pecunia.Transaction, recurring []*pecunia.Recurring,
+	now time.Time) []*AccountForecast {
+	window := newForecastWindow(now)
+	res := []*AccountForecast{}
+	for _, a := range accounts {
+		var allTs []*pecunia.Transaction
+		for _, t := range transactions[a.ID] {
+			t1 := *t
+			t1.AccountID = a.ID
+			allTs = append(allTs, &t1)
+		}
+		recurringIDs, _ := recurringInfo(allTs, recurring)
+		s := &forecastSeries{DailyHistory: map[int]int{}}
+		for _, t := range allTs {
+			window.add(s, t, t.Amount, recurringIDs[t.ID])
+		}
+		for _, r := range recurring {
+			if r.AccountID == a.ID {
+				s.Upcoming += r.AverageAmount * r.UpcomingCount(now, window.MonthEnd)
+			}
+		}
+		balance := pecunia.BalanceAt(a, transactions[a.ID], now.Add(time.Nanosecond))
+		projected, band := window.project(s, balance)
+		res = append(res, &AccountForecast{
+			AccountID:         a.ID,
+			Balance:           balance,
+			UpcomingRecurring: s.Upcoming,
+			Projected:         projected,
+			Band:              band,
+		})
+	}
+	return res
+}
+
+// recurringInfo finds the IDs of all recurring
+// transactions, and the category of the latest transaction
+// in each recurring series.
+func recurringInfo(ts []*pecunia.Transaction,
+	recurring []*pecunia.Recurring) (map[string]bool, []string) {
+	byID := map[string]*pecunia.Transaction{}
+	for _, t := range ts {
+		byID[t.ID] = t
+	}
+	ids := map[string]bool{}
+	categories := make([]string, len(recurring))
+	for i, r := range recurring {
+		for _, id := range r.TransactionIDs {
+			ids[id] = true
+			if t, ok := byID[id]; ok {
+				categories[i] = t.Category
+			}
+		}
+	}
+	return ids, categories
+}
+
+func sortedKeys(m map[string]*forecastSeries) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// Ways to group transactions.
+const (
+	ByCategory = "category"
+	ByMerchant = "merchant"
+	ByAccount  = "account"
+	ByTag      = "tag"
+	ByDay      = "day"
+	ByWeek     = "week"
+	ByMonth    = "month"
+	ByYear     = "year"
+)
+
+// A Group totals up the transactions with one key.
+type Group struct {
+	Key string
+
+	Count int
+
+	// Income is the sum of positive amounts, and Spending
+	// is the negated sum of negative amounts.
+	Income   int
+	Spending int
+	Net      int
+}
+
+func (g *Group) add(t *pecunia.Transaction) {
+	g.Count++
+	g.Net += t.Amount
+	if t.Amount > 0 {
+		g.Income += t.Amount
+	} else {
+		g.Spending -= t.Amount
+	}
+}
+
+// GroupBy totals up transactions by a grouping, which is
+// one of the By* constants.
+//
+// Groupings by time are sorted chronologically, and all
+// others are sorted from most to least spending.
+// When grouping by tag, a transaction is counted once for
+// each of its tags, and untagged transactions are grouped
+// under the empty key.
+func GroupBy(ts []*pecunia.Transaction, grouping string) ([]*Group, error) {
+	keyFunc, err := groupKeyFunc(grouping)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string]*Group{}
+	for _, t := range ts {
+		for _, key := range keyFunc(t) {
+			g, ok := groups[key]
+			if !ok {
+				g = &Group{Key: key}
+				groups[key] = g
+			}
+			g.add(t)
+		}
+	}
+	res := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		res = append(res, g)
+	}
+	if IsTimeGrouping(grouping) {
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Key < res[j].Key
+		})
+	} else {
+		sort.Slice(res, func(i, j int) bool {
+			if res[i].Spending == res[j].Spending {
+				return res[i].Key < res[j].Key
+			}
+			return res[i].Spending > res[j].Spending
+		})
+	}
+	return res, nil
+}
+
+// IsTimeGrouping checks if a grouping groups transactions
+// by time period.
+func IsTimeGrouping(grouping string) bool {
+	return grouping == ByDay || grouping == ByWeek || grouping == ByMonth || grouping == ByYear
+}
+
+// PeriodKey gets the key of the time period containing t
+// for a time grouping.
+//
+// Keys sort in chronological order.
+func PeriodKey(t time.Time, grouping string) string {
+	switch grouping {
+	case ByDay:
+		return t.Format("2006-01-02")
+	case ByWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case ByMonth:
+		return t.Format("2006-01")
+	default:
+		return t.Format("2006")
+	}
+}
+
+func groupKeyFunc(grouping string) (func(t *pecunia.Transaction) []string, error) {
+	switch grouping {
+	case ByCategory:
+		return func(t *pecunia.Transaction) []string {
+			return []string{t.Category}
+		}, nil
+	case ByMerchant:
+		return func(t *pecunia.Transaction) []string {
+			return []string{t.Merchant}
+		}, nil
+	case ByAccount:
+		return func(t *pecunia.Transaction) []string {
+			return []string{t.AccountID}
+		}, nil
+	case ByTag:
+		return func(t *pecunia.Transaction) []string {
+			if len(t.Tags) == 0 {
+				return []string{""}
+			}
+			return t.Tags
+		}, nil
+	case ByDay, ByWeek, ByMonth, ByYear:
+		return func(t *pecunia.Transaction) []string {
+			return []string{PeriodKey(t.Time, grouping)}
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown grouping: %s", grouping)
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// A NetWorthPoint records the balances of all accounts at
+// the end of a time period.
+type NetWorthPoint struct {
+	Key string
+	End time.Time
+
+	// Assets is the total balance of asset accounts, and
+	// Liabilities is the total amount owed on liability
+	// accounts.
+	Assets      int
+	Liabilities int
+	NetWorth    int
+
+	// Balances maps account IDs to balances, in the
+	// currency of each account.
+	Balances map[string]int
+}
+
+// NetWorth computes the balances of the accounts at the
+// end of every period of a time grouping which overlaps
+// [start, end).
+//
+// The transactions map account IDs to the unfiltered
+// transactions of each account. If start or end is zero,
+// the range begins or ends with the transactions.
+//
+// If currency is non-empty, the totals are converted to it
+// using the rates as of the end of each period. Otherwise,
+// the totals sum balances in every account's currency.
+func NetWorth(accounts []*pecunia.Account, transactions map[string][]*pecunia.Transaction,
+	grouping string, start, end time.Time, rates *pecunia.RateTable,
+	currency string) ([]*NetWorthPoint, error) {
+	if !IsTimeGrouping(grouping) {
+		return nil, errors.New("net worth requires a time grouping")
+	}
+	var first, last time.Time
+	for _, ts := range transactions {
+		for _, t := range ts {
+			if first.IsZero() || t.Time.Before(first) {
+				first = t.Time
+			}
+			if last.IsZero() || t.Time.After(last) {
+				last = t.Time
+			}
+		}
+	}
+	if !start.IsZero() {
+		first = start
+	}
+	if !end.IsZero() {
+		last = end.Add(-time.Nanosecond)
+	}
+	if first.IsZero() || last.Before(first) {
+		return []*NetWorthPoint{}, nil
+	}
+
+	res := []*NetWorthPoint{}
+	for p := PeriodStart(first, grouping); !p.After(last); p = NextPeriod(p, grouping) {
+		point := &NetWorthPoint{
+			Key:      PeriodKey(p, grouping),
+			End:      NextPeriod(p, grouping),
+			Balances: map[string]int{},
+		}
+		for _, a := range accounts {
+			balance := pecunia.BalanceAt(a, transactions[a.ID], point.End)
+			point.Balances[a.ID] = balance
+			if currency != "" {
+				var err error
+				balance, err = rates.Convert(balance, a.CurrencyOf(), currency, point.End)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if a.IsLiability() {
+				point.Liabilities -= balance
+			} else {
+				point.Assets += balance
+			}
+		}
+		point.NetWorth = point.Assets - point.Liabilities
+		res = append(res, point)
+	}
+	return res, nil
+}
@@ -0,0 +1,96 @@
+package report
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// A Page is one page of a sorted list of transactions.
+type Page struct {
+	Transactions []*pecunia.Transaction
+
+	// NextCursor can be passed to Paginate to get the next
+	// page. It is empty on the last page.
+	NextCursor string
+}
+
+// pageCursor records the sort key of the last transaction
+// on a page, so that the next page stays correct even if
+// transactions are added or removed in between requests.
+type pageCursor struct {
+	Sort        string
+	Descending  bool
+	Time        time.Time
+	Amount      int
+	Description string
+	ID          string
+}
+
+// Paginate returns up to limit transactions which come
+// after the cursor in the given sort order.
+//
+// The transactions must already be sorted with
+// SortTransactions using the same order. An empty cursor
+// starts from the beginning, and a non-positive limit
+// returns every remaining transaction.
+func Paginate(ts []*pecunia.Transaction, field string, descending bool, cursor string,
+	limit int) (*Page, error) {
+	less, err := transactionLess(field, descending)
+	if err != nil {
+		return nil, err
+	}
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != field || c.Descending != descending {
+			return nil, errors.New("cursor does not match sort order")
+		}
+		last := &pecunia.Transaction{
+			Time:        c.Time,
+			Amount:      c.Amount,
+			Description: c.Description,
+			ID:          c.ID,
+		}
+		ts = ts[sort.Search(len(ts), func(i int) bool {
+			return less(last, ts[i])
+		}):]
+	}
+	res := &Page{Transactions: ts}
+	if limit > 0 && limit < len(ts) {
+		res.Transactions = ts[:limit]
+		last := ts[limit-1]
+		res.NextCursor = encodeCursor(&pageCursor{
+			Sort:        field,
+			Descending:  descending,
+			Time:        last.Time,
+			Amount:      last.Amount,
+			Description: last.Description,
+			ID:          last.ID,
+		})
+	}
+	return res, nil
+}
+
+func encodeCursor(c *pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &c, nil
+}
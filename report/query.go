@@ -0,0 +1,66 @@
+// Package report computes summaries of transactions on the
+// server, such as totals by category or by month.
+package report
+
+import (
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// A Query selects the transactions to include in a report.
+type Query struct {
+	// If non-zero, only include transactions in the time
+	// range [Start, End).
+	Start time.Time
+	End   time.Time
+
+	// If non-empty, only include transactions from these
+	// accounts.
+	AccountIDs []string
+
+	// If positive, only include income. If negative, only
+	// include spending.
+	Sign int
+
+	// If set, transactions which are part of transfers
+	// between accounts are included.
+	IncludeTransfers bool
+
+	// If set, amounts should be converted to this currency
+	// before the query is applied.
+	Currency string
+}
+
+// Apply selects the transactions matching the query.
+//
+// Split transactions are expanded first, so that each
+// split is counted in its own category.
+func (q *Query) Apply(ts []*pecunia.Transaction) []*pecunia.Transaction {
+	var res []*pecunia.Transaction
+	for _, t := range pecunia.ExpandSplits(ts) {
+		if q.Match(t) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// Match checks if a single transaction matches the query,
+// without expanding its splits.
+func (q *Query) Match(t *pecunia.Transaction) bool {
+	if !q.Start.IsZero() && t.Time.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && !t.Time.Before(q.End) {
+		return false
+	}
+	if len(q.AccountIDs) > 0 && !essentials.Contains(q.AccountIDs, t.AccountID) {
+		return false
+	}
+	if (q.Sign > 0 && t.Amount < 0) || (q.Sign < 0 && t.Amount >= 0) {
+		return false
+	}
+	return t.TransferID == "" || q.IncludeTransfers
+}
@@ -0,0 +1,361 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// A Search is a parsed search query, which matches the
+// transactions satisfying all of its terms.
+//
+// See ParseSearch for the query syntax.
+type Search struct {
+	terms []searchTerm
+}
+
+type searchTerm struct {
+	negate bool
+	match  func(t *pecunia.Transaction) bool
+}
+
+// ParseSearch parses a search query.
+//
+// A query is a list of space-separated terms, all of which
+// must match. A term may be prefixed with '-' to negate it.
+// Values containing spaces may be double-quoted. The
+// supported terms are:
+//
+//	word                   description contains word
+//	desc:word              description contains word
+//	desc:/regexp/[i]       description matches a regexp
+//	merchant:name          merchant contains name
+//	category:name          category is name
+//	tag:name               transaction has the tag
+//	account:name           account name or ID is name
+//	amount>10.50           absolute amount compared in units
+//	                       (also <, >=, <=, and =)
+//	after:2006-01-02       on or after a date
+//	before:2006-01-02      before a date
+//	is:income, is:expense  by sign
+//	is:transfer            part of a transfer
+//	is:uncategorized       category is empty
+//
+// The accounts are used to resolve account names.
+// Text comparisons are case-insensitive.
+func ParseSearch(query string, accounts []*pecunia.Account) (*Search, error) {
+	tokens, err := splitSearch(query)
+	if err != nil {
+		return nil, err
+	}
+	res := &Search{}
+	for _, token := range tokens {
+		var term searchTerm
+		if strings.HasPrefix(token, "-") && len(token) > 1 {
+			term.negate = true
+			token = token[1:]
+		}
+		term.match, err = parseSearchTerm(token, accounts)
+		if err != nil {
+			return nil, err
+		}
+		res.terms = append(res.terms, term)
+	}
+	return res, nil
+}
+
+// Match checks if a transaction matches the search.
+func (s *Search) Match(t *pecunia.Transaction) bool {
+	for _, term := range s.terms {
+		if term.match(t) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the transactions matching the search,
+// along with the parts of them which match.
+//
+// Split transactions are expanded before matching, like in
+// Query.Apply. A split transaction matches if any of its
+// splits do, but only the matching splits are included in
+// the parts, which should be used for totals.
+func (s *Search) Filter(ts []*pecunia.Transaction) (matches, parts []*pecunia.Transaction) {
+	matches = []*pecunia.Transaction{}
+	parts = []*pecunia.Transaction{}
+	for _, t := range ts {
+		var matched bool
+		for _, part := range pecunia.ExpandSplits([]*pecunia.Transaction{t}) {
+			if s.Match(part) {
+				parts = append(parts, part)
+				matched = true
+			}
+		}
+		if matched {
+			matches = append(matches, t)
+		}
+	}
+	return matches, parts
+}
+
+var searchAmountExpr = regexp.MustCompile(`^amount(>=|<=|>|<|=)(-?[0-9]+(\.[0-9]*)?)$`)
+
+func parseSearchTerm(token string, accounts []*pecunia.Account) (func(t *pecunia.Transaction) bool, error) {
+	if m := searchAmountExpr.FindStringSubmatch(token); m != nil {
+		value, _ := strconv.ParseFloat(m[2], 64)
+		cents := int(math.Round(value * 100))
+		op := m[1]
+		return func(t *pecunia.Transaction) bool {
+			amount := t.Amount
+			if amount < 0 {
+				amount = -amount
+			}
+			switch op {
+			case ">":
+				return amount > cents
+			case "<":
+				return amount < cents
+			case ">=":
+				return amount >= cents
+			case "<=":
+				return amount <= cents
+			}
+			return amount == cents
+		}, nil
+	}
+
+	idx := strings.Index(token, ":")
+	if idx == -1 {
+		return containsTerm(func(t *pecunia.Transaction) string {
+			return t.Description
+		}, token), nil
+	}
+	key, value := strings.ToLower(token[:idx]), token[idx+1:]
+	switch key {
+	case "desc", "description":
+		if strings.HasPrefix(value, "/") {
+			return regexpTerm(value)
+		}
+		return containsTerm(func(t *pecunia.Transaction) string {
+			return t.Description
+		}, value), nil
+	case "merchant":
+		return containsTerm(func(t *pecunia.Transaction) string {
+			return t.Merchant
+		}, value), nil
+	case "category":
+		return func(t *pecunia.Transaction) bool {
+			if strings.EqualFold(t.Category, value) {
+				return true
+			}
+			for _, s := range t.Splits {
+				if strings.EqualFold(s.Category, value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "tag":
+		return func(t *pecunia.Transaction) bool {
+			for _, tag := range t.Tags {
+				if strings.EqualFold(tag, value) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "account":
+		ids := map[string]bool{}
+		for _, a := range accounts {
+			if strings.EqualFold(a.Name, value) || a.ID == value {
+				ids[a.ID] = true
+			}
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("no account named: %s", value)
+		}
+		return func(t *pecunia.Transaction) bool {
+			return ids[t.AccountID]
+		}, nil
+	case "after", "before":
+		date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("expected %s:YYYY-MM-DD but got %s", key, token)
+		}
+		if key == "after" {
+			return func(t *pecunia.Transaction) bool {
+				return !t.Time.Before(date)
+			}, nil
+		}
+		return func(t *pecunia.Transaction) bool {
+			return t.Time.Before(date)
+		}, nil
+	case "is":
+		switch strings.ToLower(value) {
+		case "income":
+			return func(t *pecunia.Transaction) bool { return t.Amount > 0 }, nil
+		case "expense":
+			return func(t *pecunia.Transaction) bool { return t.Amount < 0 }, nil
+		case "transfer":
+			return func(t *pecunia.Transaction) bool { return t.TransferID != "" }, nil
+		case "uncategorized":
+			return func(t *pecunia.Transaction) bool {
+				return t.Category == "" && len(t.Splits) == 0
+			}, nil
+		}
+		return nil, fmt.Errorf("unknown search term: %s", token)
+	}
+	return nil, fmt.Errorf("unknown search term: %s", token)
+}
+
+func containsTerm(field func(t *pecunia.Transaction) string, value string) func(t *pecunia.Transaction) bool {
+	value = strings.ToLower(value)
+	return func(t *pecunia.Transaction) bool {
+		return strings.Contains(strings.ToLower(field(t)), value)
+	}
+}
+
+func regexpTerm(value string) (func(t *pecunia.Transaction) bool, error) {
+	end := strings.LastIndex(value, "/")
+	if end == 0 {
+		return nil, fmt.Errorf("unterminated regular expression: %s", value)
+	}
+	pattern, flags := value[1:end], value[end+1:]
+	mode := syntax.POSIX
+	switch flags {
+	case "":
+	case "i":
+		mode |= syntax.FoldCase
+	default:
+		return nil, fmt.Errorf("unknown regular expression flags: %s", flags)
+	}
+
+	// Patterns use POSIX syntax like filters, but POSIX
+	// syntax has no flags, so the parsed pattern is compiled
+	// from its equivalent Perl syntax instead, with the
+	// leftmost-longest matching of regexp.CompilePOSIX.
+	parsed, err := syntax.Parse(pattern, mode)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := regexp.Compile(parsed.String())
+	if err != nil {
+		return nil, err
+	}
+	expr.Longest()
+	return func(t *pecunia.Transaction) bool {
+		return expr.MatchString(t.Description)
+	}, nil
+}
+
+// splitSearch splits a query on whitespace, keeping
+// double-quoted sections together and removing the quotes.
+func splitSearch(query string) ([]string, error) {
+	var res []string
+	var cur strings.Builder
+	var inQuotes, inToken bool
+	for _, r := range query {
+		if r == '"' {
+			inQuotes = !inQuotes
+			inToken = true
+			continue
+		}
+		if unicode.IsSpace(r) && !inQuotes {
+			if inToken {
+				res = append(res, cur.String())
+				cur.Reset()
+				inToken = false
+			}
+			continue
+		}
+		cur.WriteRune(r)
+		inToken = true
+	}
+	if inQuotes {
+		return nil, errors.New("unterminated quote in search")
+	}
+	if inToken {
+		res = append(res, cur.String())
+	}
+	return res, nil
+}
+
+// Sort orders for transactions.
+const (
+	SortTime        = "time"
+	SortAmount      = "amount"
+	SortDescription = "description"
+)
+
+// SortTransactions sorts transactions in place by time,
+// amount, or description.
+//
+// Ties are broken by time and then by ID, so the order is
+// deterministic.
+func SortTransactions(ts []*pecunia.Transaction, field string, descending bool) error {
+	less, err := transactionLess(field, descending)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(ts, func(i, j int) bool {
+		return less(ts[i], ts[j])
+	})
+	return nil
+}
+
+func transactionLess(field string, descending bool) (func(t1, t2 *pecunia.Transaction) bool, error) {
+	var less func(t1, t2 *pecunia.Transaction) bool
+	switch field {
+	case SortTime, "":
+		less = func(t1, t2 *pecunia.Transaction) bool { return false }
+	case SortAmount:
+		less = func(t1, t2 *pecunia.Transaction) bool { return t1.Amount < t2.Amount }
+	case SortDescription:
+		less = func(t1, t2 *pecunia.Transaction) bool {
+			return strings.ToLower(t1.Description) < strings.ToLower(t2.Description)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", field)
+	}
+	return func(t1, t2 *pecunia.Transaction) bool {
+		if descending {
+			t1, t2 = t2, t1
+		}
+		if less(t1, t2) {
+			return true
+		} else if less(t2, t1) {
+			return false
+		}
+		if !t1.Time.Equal(t2.Time) {
+			return t1.Time.Before(t2.Time)
+		}
+		return t1.ID < t2.ID
+	}, nil
+}
+
+// Totals summarizes a list of transactions.
+type Totals struct {
+	Count    int
+	Income   int
+	Spending int
+	Net      int
+}
+
+// TotalTransactions computes the Totals of transactions.
+func TotalTransactions(ts []*pecunia.Transaction) *Totals {
+	var g Group
+	for _, t := range ts {
+		g.add(t)
+	}
+	return &Totals{Count: g.Count, Income: g.Income, Spending: g.Spending, Net: g.Net}
+}
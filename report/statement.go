@@ -0,0 +1,158 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// statementTopCount is the number of merchants and
+// transactions listed in a Statement.
+const statementTopCount = 10
+
+// A BudgetActual compares a budget's limit to the actual
+// spending during a Statement.
+type BudgetActual struct {
+	Category string
+
+	// The limit is the total limit of every budget period
+	// which overlaps the statement, prorated by how much of
+	// each period is inside the statement. Rollovers are
+	// not included in the limit.
+	//
+	// The spending only includes transactions during the
+	// statement.
+	Limit int
+	Spent int
+}
+
+// A Statement summarizes the spending during a month or a
+// year, for printing or sharing.
+type Statement struct {
+	Title string
+	Start time.Time
+	End   time.Time
+
+	// If set, all amounts are in this currency.
+	Currency string
+
+	Income   int
+	Spending int
+	Net      int
+
+	// Spending by category, from most to least.
+	Categories []*Group
+
+	// The merchants with the most spending.
+	Merchants []*Group
+
+	Budgets []*BudgetActual
+
+	// The largest expenses, from largest to smallest.
+	Largest []*pecunia.Transaction
+}
+
+// MonthStatement creates a statement for the month which
+// contains t.
+func MonthStatement(ts []*pecunia.Transaction, budgets []*pecunia.Budget,
+	t time.Time) *Statement {
+	start := PeriodStart(t, ByMonth)
+	return NewStatement(ts, budgets, start.Format("January 2006"), start, NextPeriod(start, ByMonth))
+}
+
+// YearStatement creates a statement for the year which
+// contains t.
+func YearStatement(ts []*pecunia.Transaction, budgets []*pecunia.Budget,
+	t time.Time) *Statement {
+	start := PeriodStart(t, ByYear)
+	return NewStatement(ts, budgets, start.Format("2006"), start, NextPeriod(start, ByYear))
+}
+
+// NewStatement creates a statement for the time range
+// [start, end).
+//
+// The transactions should include every transaction, not
+// just the ones in the range, so that budget rollovers can
+// be computed. Transfers are ignored.
+func NewStatement(ts []*pecunia.Transaction, budgets []*pecunia.Budget, title string,
+	start, end time.Time) *Statement {
+	ts = pecunia.WithoutTransfers(ts)
+	query := &Query{Start: start, End: end}
+	inRange := query.Apply(ts)
+
+	res := &Statement{
+		Title:   title,
+		Start:   start,
+		End:     end,
+		Budgets: []*BudgetActual{},
+		Largest: []*pecunia.Transaction{},
+	}
+	total := TotalTransactions(inRange)
+	res.Income, res.Spending, res.Net = total.Income, total.Spending, total.Net
+
+	res.Categories = spendingGroups(inRange, ByCategory, 0)
+	res.Merchants = spendingGroups(inRange, ByMerchant, statementTopCount)
+
+	for _, b := range budgets {
+		actual := &BudgetActual{Category: b.Category}
+		for _, p := range pecunia.BudgetPeriods(b, ts, start, end) {
+			actual.Limit += prorateLimit(b.Limit, p.Start, p.End, start, end)
+		}
+		for _, t := range pecunia.ExpandSplits(inRange) {
+			if t.Category == b.Category && !t.Time.Before(b.Start) {
+				actual.Spent -= t.Amount
+			}
+		}
+		if actual.Limit > 0 || actual.Spent > 0 {
+			res.Budgets = append(res.Budgets, actual)
+		}
+	}
+
+	for _, t := range inRange {
+		if t.Amount < 0 {
+			res.Largest = append(res.Largest, t)
+		}
+	}
+	sort.SliceStable(res.Largest, func(i, j int) bool {
+		return res.Largest[i].Amount < res.Largest[j].Amount
+	})
+	if len(res.Largest) > statementTopCount {
+		res.Largest = res.Largest[:statementTopCount]
+	}
+
+	return res
+}
+
+// prorateLimit scales a budget period's limit by the
+// fraction of the period [periodStart, periodEnd) which is
+// inside [start, end).
+func prorateLimit(limit int, periodStart, periodEnd, start, end time.Time) int {
+	overlapStart, overlapEnd := periodStart, periodEnd
+	if start.After(overlapStart) {
+		overlapStart = start
+	}
+	if end.Before(overlapEnd) {
+		overlapEnd = end
+	}
+	if !overlapEnd.After(overlapStart) {
+		return 0
+	}
+	fraction := float64(overlapEnd.Sub(overlapStart)) / float64(periodEnd.Sub(periodStart))
+	return int(math.Round(float64(limit) * fraction))
+}
+
+// spendingGroups groups the transactions and keeps the
+// groups with spending, up to max groups if max is
+// positive.
+func spendingGroups(ts []*pecunia.Transaction, grouping string, max int) []*Group {
+	groups, _ := GroupBy(ts, grouping)
+	res := []*Group{}
+	for _, g := range groups {
+		if g.Spending > 0 && (max <= 0 || len(res) < max) {
+			res = append(res, g)
+		}
+	}
+	return res
+}
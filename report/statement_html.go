@@ -0,0 +1,229 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// Dimensions of the bar charts in statements, in pixels.
+const (
+	chartWidth      = 640
+	chartLabelWidth = 180
+	chartValueWidth = 150
+	chartRowHeight  = 24
+	chartBarHeight  = 16
+)
+
+// WriteHTML writes the statement as a self-contained HTML
+// document with inline SVG charts, suitable for printing.
+//
+// The accounts are used to look up account names.
+func (s *Statement) WriteHTML(w io.Writer, accounts []*pecunia.Account) error {
+	names := map[string]string{}
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+	funcs := template.FuncMap{
+		"amount": func(amount int) string {
+			return formatStatementAmount(amount, s.Currency)
+		},
+		"accountName": func(id string) string {
+			return names[id]
+		},
+		"date": func(t time.Time) string {
+			return t.Format("Jan 2, 2006")
+		},
+		"lastDay": func(t time.Time) time.Time {
+			return t.AddDate(0, 0, -1)
+		},
+		"groupChart": func(groups []*Group, emptyLabel string) template.HTML {
+			rows := make([]*chartRow, len(groups))
+			for i, g := range groups {
+				label := g.Key
+				if label == "" {
+					label = emptyLabel
+				}
+				rows[i] = &chartRow{Label: label, Value: g.Spending}
+			}
+			return barChart(rows, s.Currency)
+		},
+		"budgetChart": func(budgets []*BudgetActual) template.HTML {
+			rows := make([]*chartRow, len(budgets))
+			for i, b := range budgets {
+				rows[i] = &chartRow{Label: b.Category, Value: b.Spent, Limit: b.Limit, HasLimit: true}
+			}
+			return barChart(rows, s.Currency)
+		},
+	}
+	tmpl, err := template.New("statement").Funcs(funcs).Parse(statementTemplate)
+	if err != nil {
+		return essentials.AddCtx("write statement", err)
+	}
+	return essentials.AddCtx("write statement", tmpl.Execute(w, s))
+}
+
+type chartRow struct {
+	Label string
+	Value int
+
+	// If HasLimit is set, the limit is drawn as an outline
+	// behind the bar, and bars past the limit are red.
+	Limit    int
+	HasLimit bool
+}
+
+// barChart draws a horizontal bar chart as an SVG image.
+func barChart(rows []*chartRow, currency string) template.HTML {
+	if len(rows) == 0 {
+		return template.HTML(`<p class="empty">Nothing to show.</p>`)
+	}
+	var maxValue int
+	for _, r := range rows {
+		if r.Value > maxValue {
+			maxValue = r.Value
+		}
+		if r.Limit > maxValue {
+			maxValue = r.Limit
+		}
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+	barSpace := float64(chartWidth - chartLabelWidth - chartValueWidth)
+	scale := func(x int) float64 {
+		return barSpace * float64(essentials.MaxInt(x, 0)) / float64(maxValue)
+	}
+
+	var b strings.Builder
+	height := len(rows) * chartRowHeight
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" `+
+		`viewBox="0 0 %d %d">`, chartWidth, height, chartWidth, height)
+	for i, r := range rows {
+		y := i * chartRowHeight
+		barY := y + (chartRowHeight-chartBarHeight)/2
+		textY := y + chartRowHeight/2 + 4
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`,
+			chartLabelWidth-8, textY, template.HTMLEscapeString(truncateLabel(r.Label)))
+		color := "#4a7ab7"
+		if r.HasLimit {
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="#eee" stroke="#999"/>`,
+				chartLabelWidth, barY, scale(r.Limit), chartBarHeight)
+			if r.Value > r.Limit {
+				color = "#c0392b"
+			} else {
+				color = "#27ae60"
+			}
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`,
+			chartLabelWidth, barY+2, scale(r.Value), chartBarHeight-4, color)
+		valueText := formatStatementAmount(r.Value, currency)
+		if r.HasLimit {
+			valueText += " / " + formatStatementAmount(r.Limit, currency)
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`,
+			chartWidth, textY, template.HTMLEscapeString(valueText))
+	}
+	b.WriteString("</svg>")
+	return template.HTML(b.String())
+}
+
+func truncateLabel(label string) string {
+	runes := []rune(label)
+	if len(runes) > 24 {
+		return string(runes[:23]) + "…"
+	}
+	return label
+}
+
+func formatStatementAmount(amount int, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	abs := essentials.AbsInt(amount)
+	dollars := fmt.Sprint(abs / 100)
+	for i := len(dollars) - 3; i > 0; i -= 3 {
+		dollars = dollars[:i] + "," + dollars[i:]
+	}
+	res := fmt.Sprintf("%s%s.%02d", sign, dollars, abs%100)
+	if currency != "" {
+		res += " " + currency
+	}
+	return res
+}
+
+const statementTemplate = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Statement: {{.Title}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; max-width: 720px; margin: 2em auto; }
+h1 { margin-bottom: 0; }
+.period { color: #666; margin-top: 0.2em; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: 0.2em; margin-top: 1.6em; }
+section { page-break-inside: avoid; }
+svg text { font-size: 12px; fill: #222; }
+table { border-collapse: collapse; width: 100%; font-size: 13px; }
+th, td { text-align: left; padding: 0.3em 0.5em; border-bottom: 1px solid #eee; }
+td.amount, th.amount { text-align: right; white-space: nowrap; }
+.totals td { font-size: 15px; }
+.empty { color: #888; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="period">{{date .Start}} to {{date (lastDay .End)}}</p>
+
+<section>
+<table class="totals">
+<tr><td>Income</td><td class="amount">{{amount .Income}}</td></tr>
+<tr><td>Spending</td><td class="amount">{{amount .Spending}}</td></tr>
+<tr><td>Net</td><td class="amount">{{amount .Net}}</td></tr>
+</table>
+</section>
+
+<section>
+<h2>Spending by category</h2>
+{{groupChart .Categories "Uncategorized"}}
+</section>
+
+<section>
+<h2>Top merchants</h2>
+{{groupChart .Merchants "Unknown"}}
+</section>
+
+<section>
+<h2>Budget vs. actual</h2>
+{{budgetChart .Budgets}}
+</section>
+
+<section>
+<h2>Largest transactions</h2>
+{{if .Largest}}
+<table>
+<tr><th>Date</th><th>Account</th><th>Description</th><th>Category</th><th class="amount">Amount</th></tr>
+{{range .Largest}}
+<tr>
+<td>{{date .Time}}</td>
+<td>{{accountName .AccountID}}</td>
+<td>{{.Description}}</td>
+<td>{{.Category}}</td>
+<td class="amount">{{amount .Amount}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p class="empty">Nothing to show.</p>
+{{end}}
+</section>
+</body>
+</html>
+`
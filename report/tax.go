@@ -0,0 +1,121 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/unixpickle/essentials"
+	"github.com/unixpickle/pecunia/pecunia"
+)
+
+// Kinds of TaxItems.
+const (
+	TaxCategory = "category"
+	TaxTag      = "tag"
+)
+
+// A TaxItem totals the deductible transactions in one
+// category or with one tag.
+type TaxItem struct {
+	Kind string
+	Name string
+
+	// The total spending, less any refunds.
+	Total int
+
+	Transactions []*pecunia.Transaction
+}
+
+// A TaxReport totals the deductible transactions in a tax
+// year.
+type TaxReport struct {
+	Year  int
+	Start time.Time
+	End   time.Time
+
+	// The total of every deductible transaction. A
+	// transaction which appears in more than one item is
+	// only counted once.
+	Total int
+
+	Items []*TaxItem
+}
+
+// TaxYear creates a report for the tax year which starts in
+// the given calendar year.
+//
+// Split transactions are expanded, so only the deductible
+// parts of a split are included. Transfers are excluded.
+// Items are listed in the order of the settings, and each
+// item's transactions are sorted by time.
+func TaxYear(ts []*pecunia.Transaction, settings *pecunia.TaxSettings, year int) *TaxReport {
+	start, end := settings.YearBounds(year)
+	query := &Query{Start: start, End: end}
+	ts = query.Apply(ts)
+
+	res := &TaxReport{Year: year, Start: start, End: end, Items: []*TaxItem{}}
+	counted := map[*pecunia.Transaction]bool{}
+	addItem := func(kind, name string, match func(t *pecunia.Transaction) bool) {
+		item := &TaxItem{Kind: kind, Name: name, Transactions: []*pecunia.Transaction{}}
+		for _, t := range ts {
+			if !match(t) {
+				continue
+			}
+			item.Total -= t.Amount
+			item.Transactions = append(item.Transactions, t)
+			if !counted[t] {
+				counted[t] = true
+				res.Total -= t.Amount
+			}
+		}
+		sort.SliceStable(item.Transactions, func(i, j int) bool {
+			return item.Transactions[i].Time.Before(item.Transactions[j].Time)
+		})
+		res.Items = append(res.Items, item)
+	}
+	for _, category := range settings.Categories {
+		addItem(TaxCategory, category, func(t *pecunia.Transaction) bool {
+			return t.Category == category
+		})
+	}
+	for _, tag := range settings.Tags {
+		addItem(TaxTag, tag, func(t *pecunia.Transaction) bool {
+			return essentials.Contains(t.Tags, tag)
+		})
+	}
+	return res
+}
+
+// WriteCSV writes the supporting transactions of the
+// report as a CSV file, with one row per transaction in
+// each item, followed by a total row for the item.
+//
+// Amounts are written as deductions, so spending is
+// positive. The accounts are used to look up account
+// names.
+func (t *TaxReport) WriteCSV(w io.Writer, accounts []*pecunia.Account) error {
+	names := map[string]string{}
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Kind", "Name", "Date", "Account", "Description", "Amount"})
+	for _, item := range t.Items {
+		for _, tx := range item.Transactions {
+			cw.Write([]string{
+				item.Kind,
+				item.Name,
+				tx.Time.Format("2006-01-02"),
+				names[tx.AccountID],
+				tx.Description,
+				pecunia.FormatAmount(-tx.Amount),
+			})
+		}
+		cw.Write([]string{item.Kind, item.Name, "", "", "Total", pecunia.FormatAmount(item.Total)})
+	}
+	cw.Write([]string{"", "", "", "", "Total", pecunia.FormatAmount(t.Total)})
+	cw.Flush()
+	return essentials.AddCtx("write tax report", cw.Error())
+}